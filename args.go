@@ -0,0 +1,96 @@
+package callgraph
+
+import "strings"
+
+// Arg is a function argument of a stack frame.
+type Arg struct {
+	// Argument name.
+	Name string
+	// Argument value.
+	Value string
+}
+
+// ParsedArgs returns the function arguments of the stack frame parsed into
+// name/value pairs.
+func (st StackFrame) ParsedArgs() []Arg {
+	return parseArgs(st.Args)
+}
+
+// parseArgs parses the given function argument string into name/value pairs.
+// Arguments are separated by top-level commas, taking nested brackets and
+// quoted strings into account. Arguments without name (i.e. lacking "=") are
+// stored in Value.
+//
+// Example:
+//
+//    "n=23, this=0x5686a728 <sgMemCrit>" -> [{n 23} {this 0x5686a728 <sgMemCrit>}]
+func parseArgs(s string) []Arg {
+	var args []Arg
+	for _, field := range splitArgs(s) {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		arg := Arg{Value: field}
+		// Only a "=" preceding any quote or bracket separates the argument name
+		// from its value (e.g. not within "\"a=b\"" or "{x = 1}").
+		if pos := strings.IndexAny(field, "=\"'([{<"); pos != -1 && field[pos] == '=' {
+			arg.Name = strings.TrimSpace(field[:pos])
+			arg.Value = strings.TrimSpace(field[pos+1:])
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// splitArgs splits the given function argument string on top-level commas.
+// Angle brackets (e.g. of "<foo<int>>" symbol lookups) are only taken into
+// account if balanced.
+func splitArgs(s string) []string {
+	if fields, ok := splitArgsDepth(s, true); ok {
+		return fields
+	}
+	fields, _ := splitArgsDepth(s, false)
+	return fields
+}
+
+// splitArgsDepth splits the given function argument string on top-level
+// commas, optionally counting angle brackets as nesting brackets. The boolean
+// return value indicates whether all brackets were balanced.
+func splitArgsDepth(s string, angles bool) ([]string, bool) {
+	var fields []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case '<':
+			if angles {
+				depth++
+			}
+		case '>':
+			if angles && depth > 0 {
+				depth--
+			}
+		case '"', '\'':
+			// Skip quoted string or character.
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case ',':
+			if depth == 0 {
+				fields = append(fields, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	fields = append(fields, s[start:])
+	return fields, depth == 0
+}
@@ -0,0 +1,77 @@
+package callgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	golden := []struct {
+		s    string
+		want []Arg
+	}{
+		{s: "", want: nil},
+		{s: "n=23", want: []Arg{{Name: "n", Value: "23"}}},
+		{
+			s:    "argc=1, argv=0x7fffffffe6a8",
+			want: []Arg{{Name: "argc", Value: "1"}, {Name: "argv", Value: "0x7fffffffe6a8"}},
+		},
+		// Symbol lookup of pointer.
+		{
+			s:    "n=23, this=0x5686a728 <sgMemCrit>",
+			want: []Arg{{Name: "n", Value: "23"}, {Name: "this", Value: "0x5686a728 <sgMemCrit>"}},
+		},
+		// Nested templates.
+		{
+			s:    "cmp=0x1 <foo<int>>, n=2",
+			want: []Arg{{Name: "cmp", Value: "0x1 <foo<int>>"}, {Name: "n", Value: "2"}},
+		},
+		{
+			s:    "m=0x7fffffffe0a0 <cache<std::pair<int, int>, 4>>, n=2",
+			want: []Arg{{Name: "m", Value: "0x7fffffffe0a0 <cache<std::pair<int, int>, 4>>"}, {Name: "n", Value: "2"}},
+		},
+		// Function pointer.
+		{
+			s:    "f=0x401136 <handler(int, char)>, n=1",
+			want: []Arg{{Name: "f", Value: "0x401136 <handler(int, char)>"}, {Name: "n", Value: "1"}},
+		},
+		// Unbalanced angle brackets.
+		{
+			s:    "a=1 <, b=2",
+			want: []Arg{{Name: "a", Value: "1 <"}, {Name: "b", Value: "2"}},
+		},
+		// Quoted commas.
+		{
+			s:    `s=0x555555556004 "a, b", n=1`,
+			want: []Arg{{Name: "s", Value: `0x555555556004 "a, b"`}, {Name: "n", Value: "1"}},
+		},
+		{
+			s:    "c=44 ',', n=1",
+			want: []Arg{{Name: "c", Value: "44 ','"}, {Name: "n", Value: "1"}},
+		},
+		// Escaped quotes.
+		{
+			s:    `s=0x555555556004 "say \"hi, there\"", n=1`,
+			want: []Arg{{Name: "s", Value: `0x555555556004 "say \"hi, there\""`}, {Name: "n", Value: "1"}},
+		},
+		{
+			s:    `c=39 '\'', n=1`,
+			want: []Arg{{Name: "c", Value: `39 '\''`}, {Name: "n", Value: "1"}},
+		},
+		// Structures.
+		{
+			s:    "p={x = 1, y = 2}, n=1",
+			want: []Arg{{Name: "p", Value: "{x = 1, y = 2}"}, {Name: "n", Value: "1"}},
+		},
+		// Unnamed arguments.
+		{
+			s:    `"a=b", {x = 1}, 23`,
+			want: []Arg{{Value: `"a=b"`}, {Value: "{x = 1}"}, {Value: "23"}},
+		},
+	}
+	for _, g := range golden {
+		if got := parseArgs(g.s); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%q: arguments mismatch; expected %q, got %q", g.s, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,602 @@
+// Package callgraph generates call graphs by tracing executables using GDB or
+// LLDB.
+package callgraph
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Debugger traces call graphs of binary executables.
+type Debugger interface {
+	// Funcs retrieves debug information about functions of the given binary
+	// executable.
+	Funcs(binPath string) ([]Func, error)
+	// Trace traces the call graph of the specified functions in the given binary
+	// and returns the edges of the call graph.
+	Trace(binPath string, fns []Func) ([]Edge, error)
+}
+
+// Options specifies tracing options common to all debugger backends.
+type Options struct {
+	// Maximum call depth from the roots to record (0 means just the roots); a
+	// negative value means unlimited depth.
+	MaxDepth int
+	// Number of stack frames of the backtrace of each breakpoint from which to
+	// record edges between consecutive stack frames (minimum 2; i.e. callee and
+	// immediate caller).
+	Backtrace int
+	// Demangle C++ symbol names.
+	Demangle bool
+	// Command line arguments passed to the traced program.
+	Args []string
+	// Path to file whose contents are supplied to the standard input of the
+	// traced program; empty for no input. Relative paths are resolved relative
+	// to the working directory of the traced program (see WorkingDir), not to
+	// the current working directory.
+	Stdin string
+	// Working directory of the traced program; empty to use the current
+	// working directory.
+	WorkingDir string
+	// Environment variables of the traced program, each in KEY=VALUE form.
+	Env []string
+	// Duration after which the debugger and the traced program are killed; zero
+	// for no timeout.
+	Timeout time.Duration
+	// Drop edges to and from stack frames of unknown functions, rather than
+	// collapsing unknown functions into a synthetic UnknownFuncName node.
+	DropUnknown bool
+	// Process ID of running process to attach to, rather than launching the
+	// traced program; zero to launch the traced program.
+	PID int
+	// Path to core dump of a crashed process of the traced binary, from which
+	// the call chain at crash time is recorded rather than launching the
+	// traced program; empty to launch the traced program (GDB only).
+	Core string
+	// Duration of the live tracing window when attached to a running process,
+	// after which the debugger detaches; zero to trace until the process
+	// exits.
+	Duration time.Duration
+	// Resolve the function of address-only caller stack frames (e.g. of calls
+	// through vtables or function pointers) by symbol lookup of the call site
+	// address (GDB only).
+	ResolveSymbols bool
+	// Record the thread of each breakpoint hit (see StackFrame.Thread).
+	Threads bool
+	// Breakpoint conditions (e.g. "layer == 2") of traced functions, keyed by
+	// function name; breakpoints only stop when their condition holds.
+	Conditions map[string]string
+	// Record returns of traced functions to their callers as edges of kind
+	// EdgeReturn, in execution order (GDB Python mode only; see GDB.Python).
+	Returns bool
+	// Maximum number of hits recorded per breakpoint, after which the
+	// breakpoint is disabled (e.g. to bound traces of hot functions); zero for
+	// no limit (GDB only).
+	HitsPerFunc int
+	// Path prefixes stripped from source file paths of functions and stack
+	// frames (e.g. "/build" of binaries compiled on a different machine), so
+	// that source file paths of debug information and backtraces agree. The
+	// first matching prefix is stripped.
+	StripPrefixes []string
+	// Drop edges to and from functions whose name matches any of the given
+	// regular expressions (e.g. CompilerGeneratedFuncs).
+	Skip []*regexp.Regexp
+	// Writer to which the standard error output of the debugger is copied (e.g.
+	// to diagnose empty call graphs); nil to only report it on failure.
+	Stderr io.Writer
+	// Function invoked with each call graph edge as soon as it is parsed (e.g.
+	// to stream edges while tracing); nil to only return the edges once tracing
+	// completes. Edges are passed before any pruning of the call graph.
+	OnEdge func(edge Edge)
+	// Function invoked with the progress of the trace as the debugger output is
+	// parsed (i.e. whenever a breakpoint is added or hit); nil to not report
+	// progress (GDB only).
+	OnProgress func(progress Progress)
+}
+
+// Progress is the progress of a trace.
+type Progress struct {
+	// Number of breakpoints added.
+	Breakpoints int
+	// Number of breakpoint hits.
+	Hits int
+}
+
+// CompilerGeneratedFuncs matches the names of compiler and runtime generated
+// functions (e.g. static initializers and C runtime startup code), which rarely
+// carry useful signal in call graphs.
+var CompilerGeneratedFuncs = []*regexp.Regexp{
+	regexp.MustCompile(`^_GLOBAL__`),
+	regexp.MustCompile(`^__cxa_`),
+	regexp.MustCompile(`^__libc_`),
+	regexp.MustCompile(`^__static_initialization_and_destruction_`),
+	regexp.MustCompile(`^_(init|fini|start)$`),
+	regexp.MustCompile(`^(frame_dummy|register_tm_clones|deregister_tm_clones|__do_global_dtors_aux)$`),
+}
+
+// keepEdge reports whether the given parsed edge should be kept in the call
+// graph.
+func (o *Options) keepEdge(edge Edge) bool {
+	if o.DropUnknown && (edge.Src.Unknown || edge.Dst.Unknown) {
+		return false
+	}
+	for _, re := range o.Skip {
+		if re.MatchString(edge.Src.FuncName) || re.MatchString(edge.Dst.FuncName) {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultOptions returns the default tracing options.
+func DefaultOptions() Options {
+	return Options{
+		MaxDepth:  -1,
+		Backtrace: 2,
+	}
+}
+
+// backtraceWindow returns the number of stack frames of each backtrace from
+// which to record edges.
+func (o *Options) backtraceWindow() int {
+	if o.Backtrace < 2 {
+		return 2
+	}
+	return o.Backtrace
+}
+
+// backtraceEdges returns the call graph edges between consecutive stack frames
+// of the given backtrace, starting with the callee (#0) and its immediate
+// caller. A backtrace of a single stack frame (i.e. root) results in an edge
+// with missing caller information.
+func backtraceEdges(frames []StackFrame) []Edge {
+	if len(frames) == 1 {
+		return []Edge{{Dst: frames[0]}}
+	}
+	var edges []Edge
+	for i := 0; i+1 < len(frames); i++ {
+		edge := Edge{
+			Dst: frames[i],
+			Src: frames[i+1],
+		}
+		edges = append(edges, edge)
+	}
+	return edges
+}
+
+// Trace traces the call graph of the given binary executable using GDB with
+// default settings, and returns the edges of the call graph.
+func Trace(binPath string) ([]Edge, error) {
+	g := NewGDB()
+	fns, err := g.Funcs(binPath)
+	if err != nil {
+		return nil, err
+	}
+	return g.Trace(binPath, fns)
+}
+
+// Funcs retrieves debug information about functions of the given binary
+// executable using GDB with default settings.
+func Funcs(binPath string) ([]Func, error) {
+	return NewGDB().Funcs(binPath)
+}
+
+// Edge in call graph.
+type Edge struct {
+	// Caller function; or returning callee of return edges.
+	Src StackFrame
+	// Callee function; or caller returned to of return edges.
+	Dst StackFrame
+	// Kind of edge (call or return).
+	Kind EdgeKind
+	// Source code of callee source line.
+	SrcLine string
+	// Index of the traced program run that exercised the edge, when merging
+	// the call graphs of multiple runs.
+	Run int
+	// Index of the breakpoint hit that recorded the edge, in execution order of
+	// the traced program run.
+	Index int
+	// Breakpoint number (starting at 1) of the breakpoint hit that recorded the
+	// edge, corresponding to the traced function with debug information at
+	// index Breakpoint-1; or 0 if unknown.
+	Breakpoint int
+}
+
+// EdgeKind specifies the kind of a call graph edge.
+type EdgeKind int
+
+// Edge kinds.
+const (
+	// EdgeCall is a call from the caller (Src) to the callee (Dst).
+	EdgeCall EdgeKind = iota
+	// EdgeReturn is a return from the callee (Src) to its caller (Dst).
+	EdgeReturn
+)
+
+// String returns the string representation of the edge kind.
+func (kind EdgeKind) String() string {
+	switch kind {
+	case EdgeCall:
+		return "call"
+	case EdgeReturn:
+		return "return"
+	default:
+		return fmt.Sprintf("EdgeKind(%d)", int(kind))
+	}
+}
+
+// StackFrame records information about a stack frame line.
+type StackFrame struct {
+	// Stack frame number (e.g. #0).
+	StackFrameNum int
+	// Function name. Callee if (#0), otherwise caller.
+	FuncName string
+	// Function arguments.
+	Args string
+	// Source file name at function call site.
+	SrcFile string
+	// Line number at function call site.
+	LineNum int
+	// Function of stack frame is unknown (e.g. address-only frame of stripped
+	// or JIT code); FuncName is UnknownFuncName.
+	Unknown bool
+	// Program counter at the call site (i.e. return address) of caller stack
+	// frames; or 0 if not present.
+	CallSitePC uint64
+	// Debugger thread number of the thread executing the stack frame; or 0 if
+	// unknown.
+	Thread int
+	// Function of stack frame was inlined into its caller (e.g. in optimized
+	// builds).
+	Inlined bool
+}
+
+// UnknownFuncName is the synthetic function name of stack frames with unknown
+// function, into which all unknown functions are collapsed.
+const UnknownFuncName = "<unknown>"
+
+// Func contains debug information about a function.
+type Func struct {
+	// Function name, as present in stack frames (e.g. "bar" or "ns::Foo::get");
+	// or symbol name of non-debugging symbols.
+	Name string
+	// Source code file path.
+	File string
+	// Line number in source code.
+	Line int
+	// Function signature; or symbol name of non-debugging symbols.
+	Sig string
+	// Function is a non-debugging symbol (e.g. compiled without -g), lacking
+	// source file and line number information.
+	NonDebug bool
+}
+
+// DebugFuncs returns the functions with debug information of the given
+// functions.
+func DebugFuncs(fns []Func) []Func {
+	var debugFns []Func
+	for _, fn := range fns {
+		if !fn.NonDebug {
+			debugFns = append(debugFns, fn)
+		}
+	}
+	return debugFns
+}
+
+// UnhitFuncs returns the traced functions with debug information whose
+// breakpoints never recorded any edge of the given call graph.
+func UnhitFuncs(fns []Func, edges []Edge) []Func {
+	hit := make(map[int]bool)
+	for _, edge := range edges {
+		hit[edge.Breakpoint] = true
+	}
+	var unhit []Func
+	for i, fn := range DebugFuncs(fns) {
+		if !hit[i+1] {
+			unhit = append(unhit, fn)
+		}
+	}
+	return unhit
+}
+
+// NonDebugSyms returns the symbol names of the non-debugging symbols of the
+// given functions.
+func NonDebugSyms(fns []Func) []string {
+	var syms []string
+	for _, fn := range fns {
+		if fn.NonDebug {
+			syms = append(syms, fn.Sig)
+		}
+	}
+	return syms
+}
+
+// funcNameFromSig returns the function name of the given function signature,
+// with storage class, return type and parameter list removed. Namespaces and
+// classes of qualified C++ function names are retained.
+//
+// Examples:
+//
+//    "static void bar(int);" -> "bar"
+//    "const char *name(void);" -> "name"
+//    "std::map<int, bool> ns::Foo::get(int) const;" -> "ns::Foo::get"
+//    "void *operator new(unsigned long);" -> "operator new"
+//    "int main::{lambda(int)#1}::operator()(int) const;" -> "main::{lambda(int)#1}::operator()"
+func funcNameFromSig(sig string) string {
+	name := baseName(strings.TrimSuffix(strings.TrimSpace(sig), ";"))
+	// Scan backwards from the end of the qualified function name (or from the
+	// "operator" keyword of operator functions) to the end of the return type.
+	end := len(name)
+	const keyword = "operator"
+	if pos := strings.LastIndex(name, keyword); pos != -1 && isOperatorKeyword(name[:pos+len(keyword)]) {
+		if rest := name[pos+len(keyword):]; len(rest) == 0 || !isIdentChar(rest[0]) {
+			end = pos
+		}
+	}
+	depth := 0
+	for i := end - 1; i >= 0; i-- {
+		switch c := name[i]; c {
+		case '>', ')', '}':
+			depth++
+		case '<', '(', '{':
+			depth--
+		case ' ', '\t', '*', '&':
+			if depth == 0 {
+				if c == ' ' && strings.HasSuffix(name[:i], ")") && len(localScopeStart(name[i:])) > 0 {
+					// Qualifiers of enclosing function of function-local entity
+					// (e.g. " const" of "Widget::draw() const::{lambda()#1}").
+					continue
+				}
+				return name[i+1:]
+			}
+		}
+	}
+	return name
+}
+
+// localScopeStart returns the prefix of the given function name suffix
+// following the parameter list of an enclosing function, up to and including
+// the "::" scope operator of a function-local entity (e.g. lambdas and local
+// classes), or the empty string if s does not start such a function-local
+// scope. The prefix consists of optional cv-qualifiers of the enclosing
+// function followed by "::".
+//
+// Examples:
+//
+//    "::{lambda(int)#1}::operator()" -> "::"
+//    " const::{lambda()#2}::operator()" -> " const::"
+//    " const" -> ""
+func localScopeStart(s string) string {
+	rest := s
+	for {
+		trimmed := strings.TrimLeft(rest, " ")
+		switch {
+		case strings.HasPrefix(trimmed, "const"):
+			rest = trimmed[len("const"):]
+		case strings.HasPrefix(trimmed, "volatile"):
+			rest = trimmed[len("volatile"):]
+		default:
+			if strings.HasPrefix(rest, "::") {
+				return s[:len(s)-len(rest)+len("::")]
+			}
+			return ""
+		}
+	}
+}
+
+// isIdentChar reports whether the given character may be part of an
+// identifier.
+func isIdentChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// baseName returns the base name of the given function name, with the parameter
+// list (and trailing qualifiers) of C++ function names removed. Template
+// arguments, operator symbols and anonymous namespaces of the qualified
+// function name are retained.
+//
+// Examples:
+//
+//    "foo(int)" -> "foo"
+//    "ns::Foo<int, bool>::bar(int) const" -> "ns::Foo<int, bool>::bar"
+//    "(anonymous namespace)::baz(double)" -> "(anonymous namespace)::baz"
+//    "Vec::operator()(int)" -> "Vec::operator()"
+//    "Foo::bar()::{lambda(int)#1}::operator()(int) const" -> "Foo::bar()::{lambda(int)#1}::operator()"
+//
+// The parameter lists of enclosing functions of function-local entities (e.g.
+// lambdas) are retained, as they are part of the qualified function name.
+func baseName(funcName string) string {
+	const anonNamespace = "(anonymous namespace)"
+	depth := 0
+	for i := 0; i < len(funcName); i++ {
+		switch c := funcName[i]; c {
+		case '(':
+			if strings.HasPrefix(funcName[i:], anonNamespace) {
+				i += len(anonNamespace) - 1
+				continue
+			}
+			if depth == 0 {
+				// Parameter list of enclosing function of function-local
+				// entity (e.g. "bar()" of "Foo::bar()::{lambda(int)#1}").
+				if end := scanBalanced(funcName[i:]); end != -1 {
+					if prefix := localScopeStart(funcName[i+end:]); len(prefix) > 0 {
+						i += end + len(prefix) - 1
+						continue
+					}
+				}
+				return funcName[:i]
+			}
+			depth++
+		case '<', '[', '{':
+			depth++
+		case ')', '>', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case 'r':
+			// Skip operator symbols (e.g. "operator<" or "operator()"), which
+			// would otherwise upset the nesting depth.
+			if isOperatorKeyword(funcName[:i+1]) {
+				i += len(operatorSymbol(funcName[i+1:]))
+			}
+		}
+	}
+	return funcName
+}
+
+// srcPath returns the given source file path with the first matching prefix of
+// o.StripPrefixes stripped (see TrimSrcPrefix).
+func (o *Options) srcPath(path string) string {
+	return TrimSrcPrefix(path, o.StripPrefixes)
+}
+
+// TrimSrcPrefix returns the given source file path with the first of the given
+// path prefixes stripped, along with any leading path separators of the
+// remaining path. Prefixes only match entire path components.
+//
+// Examples:
+//
+//    "/build/src/foo.c", ["/build"] -> "src/foo.c"
+//    "/build/src/foo.c", ["/build/"] -> "src/foo.c"
+//    "/buildbot/foo.c", ["/build"] -> "/buildbot/foo.c"
+func TrimSrcPrefix(path string, prefixes []string) string {
+	for _, prefix := range prefixes {
+		if len(prefix) == 0 || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		rest := path[len(prefix):]
+		if !strings.HasSuffix(prefix, "/") && len(rest) > 0 && rest[0] != '/' {
+			// Prefix ends within path component.
+			continue
+		}
+		return strings.TrimLeft(rest, "/")
+	}
+	return path
+}
+
+// operatorSymbols lists the symbols of C++ operator functions, longest first.
+var operatorSymbols = []string{
+	"<=>", "<<=", ">>=", "->*",
+	"()", "[]", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "^=", "&=", "|=", "->",
+	"<", ">", "=", "!", "+", "-", "*", "/", "%", "^", "&", "|", "~", ",",
+}
+
+// operatorSymbol returns the operator symbol at the start of the given function
+// name suffix following the "operator" keyword, or the empty string if not
+// present. The longest operator symbol is chosen for which the angle brackets
+// of the remaining suffix are balanced, so that template argument lists
+// directly following the operator symbol are not mistaken for part of it.
+//
+// Examples:
+//
+//    "<< <int>" -> "<<"
+//    "<<int>" -> "<"
+//    "<<<int>" -> "<<"
+//    "<=<int>" -> "<="
+//    "()(int)" -> "()"
+func operatorSymbol(s string) string {
+	for _, sym := range operatorSymbols {
+		if strings.HasPrefix(s, sym) && balancedAngles(s[len(sym):]) {
+			return sym
+		}
+	}
+	return ""
+}
+
+// balancedAngles reports whether the angle brackets of the given function name
+// suffix are balanced, up to the start of its parameter list (if any).
+func balancedAngles(s string) bool {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		case '(':
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return depth == 0
+}
+
+// splitEnv splits the given environment variable of KEY=VALUE form into key and
+// value.
+func splitEnv(env string) (key, val string) {
+	if pos := strings.Index(env, "="); pos != -1 {
+		return env[:pos], env[pos+len("="):]
+	}
+	return env, ""
+}
+
+// stripTemplateArgs returns the given function name with the contents of
+// top-level template argument lists removed. Operator symbols (e.g.
+// "operator<<") are retained.
+//
+// Examples:
+//
+//    "std::vector<int>::push_back" -> "std::vector<>::push_back"
+//    "std::map<int, std::vector<int> >::find" -> "std::map<>::find"
+//    "Stream::operator<< <int>" -> "Stream::operator<< <>"
+//    "operator<<int>" -> "operator<<>"
+func stripTemplateArgs(funcName string) string {
+	buf := &strings.Builder{}
+	depth := 0
+	for i := 0; i < len(funcName); i++ {
+		c := funcName[i]
+		switch c {
+		case '<':
+			depth++
+			if depth == 1 {
+				buf.WriteByte(c)
+			}
+			continue
+		case '>':
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					buf.WriteByte(c)
+				}
+				continue
+			}
+		case 'r':
+			// Skip operator symbols (e.g. "operator<" or "operator->"), which
+			// would otherwise upset the nesting depth.
+			if depth == 0 && isOperatorKeyword(funcName[:i+1]) {
+				buf.WriteByte(c)
+				sym := operatorSymbol(funcName[i+1:])
+				buf.WriteString(sym)
+				i += len(sym)
+				continue
+			}
+		}
+		if depth == 0 {
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// shellQuote returns a shell-quoted version of the given command line
+// arguments, suitable for use with the run command of GDB and LLDB.
+//
+// Example:
+//
+//    ["--headless", "it's.map"] -> "'--headless' 'it'\\''s.map'"
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,161 @@
+package callgraph
+
+import "testing"
+
+func TestFuncNameFromSig(t *testing.T) {
+	golden := []struct {
+		sig  string
+		want string
+	}{
+		{sig: "int main(int, char **);", want: "main"},
+		{sig: "static void bar(int);", want: "bar"},
+		{sig: "const char *name(void);", want: "name"},
+		{sig: "int ns::Foo::get(int);", want: "ns::Foo::get"},
+		{sig: "std::map<int, bool> ns::Foo::get(int) const;", want: "ns::Foo::get"},
+		{sig: "static void (anonymous namespace)::anon(void);", want: "(anonymous namespace)::anon"},
+		{sig: "void *operator new(unsigned long);", want: "operator new"},
+		{sig: "bool Vec::operator<(Vec const&) const;", want: "Vec::operator<"},
+		{sig: "int main::{lambda(int)#1}::operator()(int) const;", want: "main::{lambda(int)#1}::operator()"},
+		{sig: "void Widget::draw() const::{lambda()#2}::operator()() const;", want: "Widget::draw() const::{lambda()#2}::operator()"},
+	}
+	for _, g := range golden {
+		got := funcNameFromSig(g.sig)
+		if got != g.want {
+			t.Errorf("%q: function name mismatch; expected %q, got %q", g.sig, g.want, got)
+		}
+	}
+}
+
+func TestBaseName(t *testing.T) {
+	golden := []struct {
+		funcName string
+		want     string
+	}{
+		{funcName: "foo(int)", want: "foo"},
+		{funcName: "foo(double)", want: "foo"},
+		{funcName: "foo", want: "foo"},
+		// Namespaced names.
+		{funcName: "ns::Foo::bar(int) const", want: "ns::Foo::bar"},
+		{funcName: "(anonymous namespace)::baz(double)", want: "(anonymous namespace)::baz"},
+		// Templated names.
+		{funcName: "ns::Foo<int, bool>::bar(int) const", want: "ns::Foo<int, bool>::bar"},
+		{funcName: "std::vector<std::pair<int, int>, std::allocator<std::pair<int, int> > >::push_back(std::pair<int, int> const&)", want: "std::vector<std::pair<int, int>, std::allocator<std::pair<int, int> > >::push_back"},
+		{funcName: "apply<void (*)(int)>(void (*)(int), int)", want: "apply<void (*)(int)>"},
+		// Operators.
+		{funcName: "Vec::operator()(int)", want: "Vec::operator()"},
+		{funcName: "Vec::operator<(Vec const&) const", want: "Vec::operator<"},
+		{funcName: "Vec::operator<=(Vec const&) const", want: "Vec::operator<="},
+		{funcName: "Stream::operator<<(int)", want: "Stream::operator<<"},
+		{funcName: "bool operator< <int>(Box<int> const&, Box<int> const&)", want: "bool operator< <int>"},
+		{funcName: "Stream& operator<< <int>(Stream&, Box<int> const&)", want: "Stream& operator<< <int>"},
+		{funcName: "Vec::operator[](unsigned long)", want: "Vec::operator[]"},
+		{funcName: "Vec::operator->() const", want: "Vec::operator->"},
+		// Function-local entities.
+		{funcName: "Foo::bar()::{lambda(int)#1}::operator()(int) const", want: "Foo::bar()::{lambda(int)#1}::operator()"},
+	}
+	for _, g := range golden {
+		got := baseName(g.funcName)
+		if got != g.want {
+			t.Errorf("%q: base name mismatch; expected %q, got %q", g.funcName, g.want, got)
+		}
+	}
+}
+
+func TestStripTemplateArgs(t *testing.T) {
+	golden := []struct {
+		funcName string
+		want     string
+	}{
+		{funcName: "foo", want: "foo"},
+		{funcName: "std::vector<int>::push_back", want: "std::vector<>::push_back"},
+		{funcName: "std::map<int, std::vector<int> >::find", want: "std::map<>::find"},
+		{funcName: "ns::Box<ns::Item<int> >::get", want: "ns::Box<>::get"},
+		// Operators with template arguments.
+		{funcName: "operator< <int>", want: "operator< <>"},
+		{funcName: "operator<<int>", want: "operator<<>"},
+		{funcName: "operator<= <int>", want: "operator<= <>"},
+		{funcName: "operator<=<int>", want: "operator<=<>"},
+		{funcName: "Stream::operator<< <int>", want: "Stream::operator<< <>"},
+		{funcName: "Stream::operator<<<int>", want: "Stream::operator<<<>"},
+		{funcName: "Box<int>::operator<", want: "Box<>::operator<"},
+		{funcName: "Box<int>::operator->", want: "Box<>::operator->"},
+		{funcName: "Box<int>::operator>>=", want: "Box<>::operator>>="},
+	}
+	for _, g := range golden {
+		got := stripTemplateArgs(g.funcName)
+		if got != g.want {
+			t.Errorf("%q: function name mismatch; expected %q, got %q", g.funcName, g.want, got)
+		}
+	}
+}
+
+func TestOperatorSymbol(t *testing.T) {
+	golden := []struct {
+		s    string
+		want string
+	}{
+		{s: "<", want: "<"},
+		{s: "<=>", want: "<=>"},
+		{s: "<< <int>", want: "<<"},
+		{s: "<<int>", want: "<"},
+		{s: "<<<int>", want: "<<"},
+		{s: "<=<int>", want: "<="},
+		{s: "()(int)", want: "()"},
+		{s: "<<(int)", want: "<<"},
+		{s: " new", want: ""},
+	}
+	for _, g := range golden {
+		if got := operatorSymbol(g.s); got != g.want {
+			t.Errorf("%q: operator symbol mismatch; expected %q, got %q", g.s, g.want, got)
+		}
+	}
+}
+
+func TestFuncNameFromSigLocalScope(t *testing.T) {
+	golden := []struct {
+		sig  string
+		want string
+	}{
+		{sig: "int (anonymous namespace)::f(int);", want: "(anonymous namespace)::f"},
+		{sig: "void ns::(anonymous namespace)::Impl::run();", want: "ns::(anonymous namespace)::Impl::run"},
+		{sig: "void main::{lambda()#1}::operator()() const;", want: "main::{lambda()#1}::operator()"},
+		{sig: "int (anonymous namespace)::g()::{lambda(int)#1}::operator()(int) const;", want: "(anonymous namespace)::g()::{lambda(int)#1}::operator()"},
+		{sig: "void Widget::draw() const::{lambda(int, int)#2}::operator()(int, int) const;", want: "Widget::draw() const::{lambda(int, int)#2}::operator()"},
+	}
+	for _, g := range golden {
+		got := funcNameFromSig(g.sig)
+		if got != g.want {
+			t.Errorf("%q: function name mismatch; expected %q, got %q", g.sig, g.want, got)
+		}
+	}
+}
+
+func TestTrimSrcPrefix(t *testing.T) {
+	golden := []struct {
+		path     string
+		prefixes []string
+		want     string
+	}{
+		{path: "/build/src/foo.c", prefixes: []string{"/build"}, want: "src/foo.c"},
+		// Trailing slash.
+		{path: "/build/src/foo.c", prefixes: []string{"/build/"}, want: "src/foo.c"},
+		{path: "/build//src/foo.c", prefixes: []string{"/build"}, want: "src/foo.c"},
+		// Prefix not matching.
+		{path: "/home/u/src/foo.c", prefixes: []string{"/build"}, want: "/home/u/src/foo.c"},
+		{path: "src/foo.c", prefixes: []string{"/src"}, want: "src/foo.c"},
+		// Partial path component match.
+		{path: "/srcfoo/bar.c", prefixes: []string{"/src"}, want: "/srcfoo/bar.c"},
+		{path: "/src/bar.c", prefixes: []string{"/src"}, want: "bar.c"},
+		// First matching prefix is stripped.
+		{path: "/build/src/foo.c", prefixes: []string{"/buildbot", "/build/src", "/build"}, want: "foo.c"},
+		// No prefixes.
+		{path: "/build/src/foo.c", prefixes: nil, want: "/build/src/foo.c"},
+		{path: "/build/src/foo.c", prefixes: []string{""}, want: "/build/src/foo.c"},
+	}
+	for _, g := range golden {
+		got := TrimSrcPrefix(g.path, g.prefixes)
+		if got != g.want {
+			t.Errorf("%q (prefixes %q): path mismatch; expected %q, got %q", g.path, g.prefixes, g.want, got)
+		}
+	}
+}
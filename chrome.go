@@ -0,0 +1,140 @@
+package callgraph
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// chromeEvent is the JSON representation of a Chrome trace event.
+//
+// ref: https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type chromeEvent struct {
+	// Function name.
+	Name string `json:"name"`
+	// Event phase; "B" (begin) or "E" (end).
+	Ph string `json:"ph"`
+	// Timestamp; index of breakpoint hit.
+	Ts int `json:"ts"`
+	// Process ID; index of traced program run.
+	Pid int `json:"pid"`
+	// Thread ID; debugger thread number of the traced thread.
+	Tid int `json:"tid"`
+}
+
+// WriteChrome writes the given call graph to w in Chrome trace event format
+// (as used by chrome://tracing and Perfetto), using the sequence of breakpoint
+// hits as ordered events. The edges are expected in execution order. Return
+// edges (see EdgeReturn) are ignored.
+//
+// The call stack is inferred from the call chain of each breakpoint hit (i.e.
+// the callee and its callers within the backtrace window, see
+// Options.Backtrace); functions above the outermost caller of the call chain
+// on the inferred call stack are considered to have returned once the call
+// chain diverges from the call stack. A call stack is inferred per thread (see
+// StackFrame.Thread), and each thread is written as a separate track.
+func WriteChrome(w io.Writer, edges []Edge) error {
+	edges, _ = SplitReturns(edges)
+	events := make([]chromeEvent, 0, 2*len(edges))
+	// Inferred call stack of function names, keyed by thread.
+	stacks := make(map[int][]string)
+	run := 0
+	ts := 0
+	// unwind ends the events of functions located above the n bottom-most
+	// functions of the call stack of the given thread.
+	unwind := func(tid, n int) {
+		stack := stacks[tid]
+		for len(stack) > n {
+			name := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			events = append(events, chromeEvent{Name: name, Ph: "E", Ts: ts, Pid: run, Tid: tid})
+		}
+		stacks[tid] = stack
+	}
+	// unwindAll ends the events of all functions on the call stacks of all
+	// threads, in order of thread.
+	unwindAll := func() {
+		var tids []int
+		for tid := range stacks {
+			tids = append(tids, tid)
+		}
+		sort.Ints(tids)
+		for _, tid := range tids {
+			unwind(tid, 0)
+		}
+	}
+	// push begins the events of the given functions, pushed onto the call
+	// stack of the given thread in order.
+	push := func(tid int, names []string) {
+		for _, name := range names {
+			stacks[tid] = append(stacks[tid], name)
+			events = append(events, chromeEvent{Name: name, Ph: "B", Ts: ts, Pid: run, Tid: tid})
+		}
+	}
+	for i := 0; i < len(edges); {
+		edge := edges[i]
+		if edge.Run != run {
+			ts++
+			unwindAll()
+			run = edge.Run
+		}
+		ts = edge.Index
+		tid := edge.Dst.Thread
+		// Call chain of breakpoint hit, from outermost caller to callee. The
+		// edges of transitive callers (i.e. with callee stack frame #1 or
+		// above) follow the edge of the callee (#0), as recorded by
+		// backtraceEdges.
+		chain := []string{edge.Dst.FuncName}
+		if len(edge.Src.FuncName) > 0 {
+			chain = append([]string{edge.Src.FuncName}, chain...)
+		}
+		for i++; i < len(edges); i++ {
+			next := edges[i]
+			if next.Run != run || next.Dst.Thread != tid || next.Dst.StackFrameNum == 0 || next.Dst.FuncName != chain[0] || len(next.Src.FuncName) == 0 {
+				break
+			}
+			chain = append([]string{next.Src.FuncName}, chain...)
+		}
+		if len(edge.Src.FuncName) == 0 {
+			// Root; restart call stack from callee.
+			unwind(tid, 0)
+			push(tid, chain)
+			continue
+		}
+		// Locate outermost caller on call stack.
+		stack := stacks[tid]
+		n := -1
+		for j := len(stack) - 1; j >= 0; j-- {
+			if stack[j] == chain[0] {
+				n = j + 1
+				break
+			}
+		}
+		if n == -1 {
+			// Caller not on call stack (e.g. untraced function); restart call
+			// stack from caller.
+			unwind(tid, 0)
+			push(tid, chain)
+			continue
+		}
+		// Skip intermediate callers already on the call stack; the callee is
+		// always a new call.
+		chain = chain[1:]
+		for len(chain) > 1 && n < len(stack) && stack[n] == chain[0] {
+			chain = chain[1:]
+			n++
+		}
+		unwind(tid, n)
+		push(tid, chain)
+	}
+	ts++
+	unwindAll()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(events); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package callgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWriteChromeBacktrace(t *testing.T) {
+	frame := func(num int, name string) StackFrame {
+		return StackFrame{StackFrameNum: num, FuncName: name}
+	}
+	// Edges of -backtrace 3, where main calls foo twice, and foo calls bar
+	// through the untraced function helper.
+	edges := []Edge{
+		{Dst: frame(0, "main"), Index: 0},
+		{Src: frame(1, "main"), Dst: frame(0, "foo"), Index: 1},
+		{Src: frame(1, "helper"), Dst: frame(0, "bar"), Index: 2},
+		{Src: frame(2, "foo"), Dst: frame(1, "helper"), Index: 2},
+		{Src: frame(1, "main"), Dst: frame(0, "foo"), Index: 3},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteChrome(buf, edges); err != nil {
+		t.Fatalf("unable to write Chrome trace; %+v", err)
+	}
+	var events []chromeEvent
+	if err := json.Unmarshal(buf.Bytes(), &events); err != nil {
+		t.Fatalf("unable to parse Chrome trace; %v", err)
+	}
+	var got []string
+	for _, event := range events {
+		got = append(got, event.Ph+" "+event.Name)
+	}
+	want := []string{
+		"B main",
+		"B foo",
+		"B helper",
+		"B bar",
+		"E bar",
+		"E helper",
+		"E foo",
+		"B foo",
+		"E foo",
+		"E main",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestWriteChromeThreads(t *testing.T) {
+	frame := func(num int, name string, thread int) StackFrame {
+		return StackFrame{StackFrameNum: num, FuncName: name, Thread: thread}
+	}
+	// Interleaved breakpoint hits of threads 1 and 2.
+	edges := []Edge{
+		{Dst: frame(0, "main", 1), Index: 0},
+		{Dst: frame(0, "worker", 2), Index: 1},
+		{Src: frame(1, "main", 1), Dst: frame(0, "foo", 1), Index: 2},
+		{Src: frame(1, "worker", 2), Dst: frame(0, "bar", 2), Index: 3},
+		{Src: frame(1, "main", 1), Dst: frame(0, "baz", 1), Index: 4},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteChrome(buf, edges); err != nil {
+		t.Fatalf("unable to write Chrome trace; %+v", err)
+	}
+	var events []chromeEvent
+	if err := json.Unmarshal(buf.Bytes(), &events); err != nil {
+		t.Fatalf("unable to parse Chrome trace; %v", err)
+	}
+	got := make(map[int][]string)
+	for _, event := range events {
+		got[event.Tid] = append(got[event.Tid], event.Ph+" "+event.Name)
+	}
+	want := map[int][]string{
+		1: {"B main", "B foo", "E foo", "B baz", "E baz", "E main"},
+		2: {"B worker", "B bar", "E bar", "E worker"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events mismatch; expected %q, got %q", want, got)
+	}
+}
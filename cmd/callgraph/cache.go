@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mewrev/callgraph"
+	"github.com/pkg/errors"
+)
+
+// cachedFuncs returns the functions of the given binary executable, as
+// retrieved by getFuncs. The result is cached under the user cache directory
+// (e.g. $XDG_CACHE_HOME/callgraph), keyed by the SHA-256 hash and modification
+// time of the binary, and reused on subsequent invocations. Failure to read or
+// write the cache is not fatal.
+func cachedFuncs(dbg debuggerBackend, binPath string, opts options) ([]callgraph.Func, error) {
+	cachePath, err := funcsCachePath(binPath, opts)
+	if err != nil {
+		debugLog.Printf("unable to locate function cache of %q: %v", binPath, err)
+		return getFuncs(dbg, binPath, opts)
+	}
+	if buf, err := ioutil.ReadFile(cachePath); err == nil {
+		var fns []callgraph.Func
+		if err := json.Unmarshal(buf, &fns); err == nil {
+			debugLog.Printf("using cached functions of %q from %q", binPath, cachePath)
+			return fns, nil
+		}
+		debugLog.Printf("ignoring invalid function cache %q: %v", cachePath, err)
+	}
+	fns, err := getFuncs(dbg, binPath, opts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := writeFuncsCache(cachePath, fns); err != nil {
+		debugLog.Printf("unable to write function cache %q: %v", cachePath, err)
+	}
+	return fns, nil
+}
+
+// funcsCacheVersion is the version of the function cache format, which is
+// incremented whenever the fields of callgraph.Func change.
+const funcsCacheVersion = 2
+
+// funcsCachePath returns the path of the function cache of the given binary
+// executable, keyed by the SHA-256 hash and modification time of the binary,
+// the debugger executable, and the function discovery options.
+func funcsCachePath(binPath string, opts options) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	f, err := os.Open(binPath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.WithStack(err)
+	}
+	// The function list depends on the function discovery source, on
+	// demangling of symbol names and on stripped source file path prefixes.
+	fmt.Fprintf(h, "\x00%d\x00%s\x00%s\x00%t", fi.ModTime().UnixNano(), opts.debugger, opts.funcsSource, opts.traceOpts.Demangle)
+	// The output of the debugger differs between debugger builds.
+	fmt.Fprintf(h, "\x00%s", resolveExecutable(debuggerPath(opts)))
+	for _, prefix := range opts.traceOpts.StripPrefixes {
+		fmt.Fprintf(h, "\x00%s", prefix)
+	}
+	fmt.Fprintf(h, "\x00v%d", funcsCacheVersion)
+	name := hex.EncodeToString(h.Sum(nil)) + ".json"
+	return filepath.Join(cacheDir, "callgraph", name), nil
+}
+
+// resolveExecutable returns the resolved path of the given executable, as
+// located in $PATH with symbolic links evaluated, followed by its modification
+// time, thus changing when the executable is replaced (e.g. upgraded). The
+// given path is returned as is if the executable cannot be located.
+func resolveExecutable(path string) string {
+	p, err := exec.LookPath(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return p
+	}
+	return fmt.Sprintf("%s@%d", p, fi.ModTime().UnixNano())
+}
+
+// writeFuncsCache stores the given functions to the function cache at
+// cachePath.
+func writeFuncsCache(cachePath string, fns []callgraph.Func) error {
+	buf, err := json.Marshal(fns)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := ioutil.WriteFile(cachePath, buf, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
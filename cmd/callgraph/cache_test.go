@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewrev/callgraph"
+)
+
+func TestFuncsCachePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "callgraph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setCacheDir(t, dir)
+	binPath := filepath.Join(dir, "test")
+	if err := ioutil.WriteFile(binPath, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Stand-in debugger executables.
+	gdb1 := filepath.Join(dir, "gdb1")
+	gdb2 := filepath.Join(dir, "gdb2")
+	for _, path := range []string{gdb1, gdb2} {
+		if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := options{debugger: "gdb", gdbPath: gdb1, funcsSource: "gdb"}
+	want, err := funcsCachePath(binPath, opts)
+	if err != nil {
+		t.Fatalf("unable to locate function cache; %+v", err)
+	}
+	if dir := filepath.Dir(want); dir != filepath.Join(cacheDir(t), "callgraph") {
+		t.Errorf("cache directory mismatch; expected %q, got %q", filepath.Join(cacheDir(t), "callgraph"), dir)
+	}
+	got, err := funcsCachePath(binPath, opts)
+	if err != nil {
+		t.Fatalf("unable to locate function cache; %+v", err)
+	}
+	if got != want {
+		t.Errorf("cache path of same binary and options mismatch; expected %q, got %q", want, got)
+	}
+	// Options which change the function list must change the cache key.
+	golden := []struct {
+		desc string
+		opts options
+	}{
+		{desc: "debugger executable", opts: options{debugger: "gdb", gdbPath: gdb2, funcsSource: "gdb"}},
+		{desc: "debugger backend", opts: options{debugger: "lldb", gdbPath: gdb1, funcsSource: "gdb"}},
+		{desc: "function source", opts: options{debugger: "gdb", gdbPath: gdb1, funcsSource: "dwarf"}},
+		{desc: "demangling", opts: options{debugger: "gdb", gdbPath: gdb1, funcsSource: "gdb", traceOpts: callgraph.Options{Demangle: true}}},
+		{desc: "stripped prefixes", opts: options{debugger: "gdb", gdbPath: gdb1, funcsSource: "gdb", traceOpts: callgraph.Options{StripPrefixes: []string{"/src/"}}}},
+	}
+	for _, g := range golden {
+		got, err := funcsCachePath(binPath, g.opts)
+		if err != nil {
+			t.Errorf("%s: unable to locate function cache; %+v", g.desc, err)
+			continue
+		}
+		if got == want {
+			t.Errorf("%s: cache path unchanged; got %q", g.desc, got)
+		}
+	}
+	// Contents of binary.
+	if err := ioutil.WriteFile(binPath, []byte("rebuilt binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	got, err = funcsCachePath(binPath, opts)
+	if err != nil {
+		t.Fatalf("unable to locate function cache; %+v", err)
+	}
+	if got == want {
+		t.Errorf("cache path of rebuilt binary unchanged; got %q", got)
+	}
+}
+
+func TestCachedFuncsInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "callgraph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setCacheDir(t, dir)
+	binPath := filepath.Join(dir, "test")
+	if err := ioutil.WriteFile(binPath, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	funcsLog := filepath.Join(dir, "funcs.log")
+	const output = `All defined functions:
+
+File test.c:
+9:	int main(int, char **);
+17:	static void foo(int);
+`
+	if err := ioutil.WriteFile(funcsLog, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := options{debugger: "gdb", gdbPath: "gdb", funcsSource: "gdb", funcsLog: funcsLog}
+	cachePath, err := funcsCachePath(binPath, opts)
+	if err != nil {
+		t.Fatalf("unable to locate function cache; %+v", err)
+	}
+	// Corrupt function cache.
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cachePath, []byte("[{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fns, err := cachedFuncs(callgraph.NewGDB(), binPath, opts)
+	if err != nil {
+		t.Fatalf("unable to retrieve functions; %+v", err)
+	}
+	if len(fns) != 2 {
+		t.Fatalf("number of functions mismatch; expected 2, got %d (%+v)", len(fns), fns)
+	}
+	// Invalid function cache is replaced.
+	if err := os.Remove(funcsLog); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := cachedFuncs(callgraph.NewGDB(), binPath, opts)
+	if err != nil {
+		t.Fatalf("unable to retrieve cached functions; %+v", err)
+	}
+	if len(cached) != len(fns) {
+		t.Errorf("number of cached functions mismatch; expected %d, got %d", len(fns), len(cached))
+	}
+}
+
+// setCacheDir sets the user cache directory to dir for the duration of the
+// test.
+func setCacheDir(t *testing.T, dir string) {
+	for _, key := range []string{"XDG_CACHE_HOME", "HOME", "LocalAppData"} {
+		old, ok := os.LookupEnv(key)
+		os.Setenv(key, dir)
+		key := key
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+// cacheDir returns the user cache directory.
+func cacheDir(t *testing.T) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mewrev/callgraph"
+	"github.com/pkg/errors"
+)
+
+// loadBaseline parses the given JSON file of a previously saved call graph (as
+// output by -format json), and returns its edges.
+func loadBaseline(path string) ([]callgraph.Edge, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	edges, err := callgraph.ReadJSON(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse call graph file %q", path)
+	}
+	return edges, nil
+}
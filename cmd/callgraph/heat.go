@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// loadHeat parses the given CSV file of per-function metrics (e.g. profiling
+// samples from perf or gcov), one "funcname,value" record per line, and returns
+// the value of each function keyed by function name. A header line (i.e. first
+// record with a non-numeric value) is skipped.
+//
+// Example:
+//
+//    funcname,value
+//    main,12
+//    foo,3400.5
+func loadHeat(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse heat file %q", path)
+	}
+	heat := make(map[string]float64)
+	for i, record := range records {
+		name := strings.TrimSpace(record[0])
+		v, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			if i == 0 {
+				// Skip header.
+				continue
+			}
+			return nil, errors.Wrapf(err, "invalid value of function %q in heat file %q", name, path)
+		}
+		heat[name] = v
+	}
+	return heat, nil
+}
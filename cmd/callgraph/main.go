@@ -1,6 +1,7 @@
 // Inspired by https://github.com/erszcz/callgraph
 
-// The callgraph tool generates call graphs by tracing executables using GDB.
+// The callgraph tool generates call graphs by tracing executables using GDB or
+// LLDB.
 package main
 
 import (
@@ -8,414 +9,1338 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/kr/pretty"
+	"github.com/mewrev/callgraph"
 	"github.com/pkg/errors"
 )
 
+// debugLog is a logger which logs diagnostic messages; discarded unless -v is set.
+var debugLog = log.New(ioutil.Discard, "", log.LstdFlags)
+
 func main() {
 	// Parse command line arguments.
 	var (
-		// Output path.
-		output string
+		// Command line options.
+		opts = options{
+			traceOpts: callgraph.DefaultOptions(),
+		}
+		// Path to file listing command line arguments of traced program runs.
+		runsPath string
+		// Regular expressions of functions to include and exclude.
+		include, exclude string
+		// Path to file listing regular expressions of functions to exclude.
+		excludePath string
+		// Comma-separated list of function names to instrument.
+		funcNames string
+		// Drop edges to and from compiler-generated functions.
+		skipCXA bool
+		// Regular expression of additional function names to drop edges to and
+		// from.
+		skip string
+		// Output diagnostic messages.
+		verbose bool
+		// Print version information and exit.
+		showVersion bool
+		// Report progress of traces.
+		showProgress bool
+		// Suppress progress reports, even if -progress is set.
+		quiet bool
+		// Breakpoint conditions, each in FUNC:EXPR form.
+		conditions stringsFlag
+		// Node label renames, each in PATTERN=>REPLACEMENT form.
+		renames stringsFlag
+		// Default DOT attributes of the graph, nodes and edges, each in
+		// KEY=VALUE form.
+		graphAttrs, nodeAttrs, edgeAttrs stringsFlag
+		// Path to CSV file of per-function metrics.
+		heatPath string
+		// Path to JSON file of baseline call graph to diff against.
+		diffPath string
 	)
-	flag.StringVar(&output, "o", "", "output path")
-	flag.Parse()
+	// Default to $GDB if set.
+	defaultGDBPath := "gdb"
+	if path, ok := os.LookupEnv("GDB"); ok && len(path) > 0 {
+		defaultGDBPath = path
+	}
+	flag.StringVar(&opts.output, "o", "", "output path, or \"-\" for standard output (DOT output is rendered using Graphviz for .svg, .png and .pdf extensions)")
+	flag.StringVar(&opts.format, "f", "dot", "output format (dot, json, jsonl, mermaid, graphml, chrome, csv, gexf, plantuml, d3, tree or text)")
+	flag.StringVar(&opts.format, "format", "dot", "output format (dot, json, jsonl, mermaid, graphml, chrome, csv, gexf, plantuml, d3, tree or text)")
+	flag.IntVar(&opts.traceOpts.MaxDepth, "max-depth", -1, "maximum call depth from the roots to record (0 means just the roots; -1 for unlimited)")
+	flag.StringVar(&opts.gdbLog, "gdb-log", "", "parse pre-captured GDB trace output from file instead of invoking GDB")
+	flag.StringVar(&opts.funcsLog, "funcs-log", "", "parse pre-captured GDB function listing from file instead of invoking GDB")
+	flag.StringVar(&opts.gdbPath, "gdb", defaultGDBPath, "path to GDB executable (falls back to $GDB)")
+	flag.BoolVar(&opts.merge, "merge", false, "merge the call graphs of multiple binary executables into one graph, qualifying functions of the same name but different source file by binary name")
+	flag.BoolVar(&opts.gdbPython, "gdb-python", false, "register breakpoints using a GDB Python script recording backtraces as JSON, rather than one commands block per breakpoint (faster for large binaries)")
+	flag.StringVar(&opts.breakAfter, "breakpoint-after", "", "retrieve functions once the traced program reaches FUNC (e.g. after plugins are loaded using dlopen) and add pending breakpoints, so that functions of shared libraries loaded at runtime are traced (GDB only)")
+	flag.BoolVar(&showProgress, "progress", false, "report the number of breakpoints added and hit while tracing to standard error")
+	flag.BoolVar(&quiet, "quiet", false, "suppress progress reports, overriding -progress")
+	flag.BoolVar(&opts.showStderr, "show-gdb-stderr", false, "print the standard error output of the debugger, even if tracing succeeds")
+	flag.StringVar(&opts.rbreak, "rbreak", "", "add breakpoints to functions matching REGEX using the GDB rbreak command, rather than enumerating functions (GDB only)")
+	flag.BoolVar(&opts.traceOpts.Demangle, "demangle", false, "demangle C++ symbol names")
+	flag.StringVar(&opts.traceOpts.Stdin, "stdin", "", "supply contents of file to standard input of the traced program")
+	flag.DurationVar(&opts.traceOpts.Timeout, "timeout", 0, "kill the debugger and traced program after the given duration (e.g. 30s); 0 for no timeout")
+	flag.StringVar(&opts.debugger, "debugger", "gdb", "debugger backend (gdb or lldb)")
+	flag.StringVar(&runsPath, "runs", "", "trace one run per line of file, each line listing command line arguments (and optional \"<FILE\" stdin redirect); the call graphs of all runs are merged")
+	flag.StringVar(&include, "include", "", "only instrument functions whose signature or source file matches REGEX")
+	flag.StringVar(&exclude, "exclude", "", "do not instrument functions whose signature or source file matches REGEX (applied after -include)")
+	flag.Var((*stringsFlag)(&opts.files), "files", "only instrument functions defined in source files matching GLOB (e.g. 'src/net/*.c'; may be repeated)")
+	flag.StringVar(&excludePath, "exclude-file", "", "do not instrument functions whose signature or source file matches any REGEX of FILE, one per line ('#' comments and blank lines are ignored; combined with -exclude)")
+	flag.BoolVar(&opts.cluster, "cluster", false, "group nodes into clusters by source file in DOT output")
+	flag.BoolVar(&opts.highlightCycles, "highlight-cycles", false, "color nodes participating in cycles (i.e. recursion) red in DOT output")
+	flag.BoolVar(&opts.sortEdges, "sort", true, "sort edges of DOT output by caller, callee and arguments for deterministic output (-sort=false for execution order)")
+	flag.IntVar(&opts.maxOut, "max-out", 0, "limit the number of callees per node in DOT output to the N most frequently called, collapsing the rest into a \"... (+K more)\" node (0 for no limit)")
+	flag.IntVar(&opts.minCount, "min-count", 0, "prune edges observed fewer than N times, and functions isolated as a result")
+	flag.StringVar(&opts.root, "root", "", "treat FUNC as the root of the call graph, reparenting callees of missing or untraced callers under FUNC")
+	flag.StringVar(&opts.main, "main", "main", "mark FUNC as the program entry; drawn in bold with a double border in DOT output, and marked as root (i.e. edge without caller) in all output formats if present in the call graph; empty to disable")
+	flag.BoolVar(&opts.showCount, "show-count", false, "append the number of calls of each edge to its line in text output")
+	flag.BoolVar(&opts.noArgs, "no-args", false, "omit callee arguments from edge labels in DOT output")
+	flag.BoolVar(&opts.labelLocation, "label-location", false, "include the source location of each function definition (e.g. test.c:17) in node labels of DOT output")
+	flag.BoolVar(&opts.nodeIDs, "node-ids", false, "identify nodes by numeric IDs declared once with the function name as label, rather than repeating function names in each edge of DOT output (smaller output for large graphs)")
+	flag.BoolVar(&opts.callerArgs, "caller-args", false, "include caller arguments as edge sub-label in DOT output")
+	flag.BoolVar(&opts.traceOpts.DropUnknown, "drop-unknown", false, "drop edges to and from unknown (\"??\") functions rather than collapsing them into an <unknown> node")
+	flag.StringVar(&funcNames, "funcs", "", "only instrument the listed functions (comma-separated list of function names)")
+	flag.BoolVar(&opts.includeNonDebug, "include-nondebug", false, "include non-debugging symbols (functions compiled without -g) as grey nodes in DOT output")
+	flag.BoolVar(&verbose, "v", false, "verbose output (log diagnostic messages to standard error)")
+	flag.BoolVar(&verbose, "verbose", false, "verbose output (log diagnostic messages to standard error)")
+	flag.BoolVar(&showVersion, "version", false, "print the version of callgraph, the debugger backend (see -debugger and -gdb) and Go, and exit")
+	flag.IntVar(&opts.traceOpts.PID, "pid", 0, "attach to running process with the given PID rather than launching the binary (GDB only)")
+	flag.DurationVar(&opts.traceOpts.Duration, "duration", 0, "detach from the process attached to by -pid after the given duration (e.g. 30s); 0 to trace until the process exits")
+	flag.BoolVar(&opts.showSrcLine, "show-srcline", false, "include callee source line as edge sub-label in DOT output")
+	flag.StringVar(&opts.urlScheme, "url-scheme", "", "link nodes to source location using URL scheme (e.g. \"file://\" or \"vscode://file/\") in DOT output")
+	flag.BoolVar(&opts.reverse, "reverse", false, "reverse call graph; invert edges so that callees point to their callers")
+	flag.StringVar(&opts.focus, "focus", "", "only output the transitive callers of function FUNC (or its neighborhood if -radius is set)")
+	flag.IntVar(&opts.radius, "radius", -1, "with -focus, only output functions within N hops of FUNC in either direction (-1 for transitive callers of FUNC)")
+	flag.IntVar(&opts.traceOpts.Backtrace, "backtrace", 2, "number of stack frames of each backtrace from which to record edges between consecutive frames (minimum 2)")
+	flag.StringVar(&opts.rankDir, "rankdir", "", "direction of graph layout in DOT output (TB, LR, BT or RL)")
+	flag.BoolVar(&opts.weightEdges, "weight-edges", false, "scale pen width of edges logarithmically based on call count in DOT output")
+	flag.StringVar(&opts.entry, "entry", "", "only output functions reachable from entry function FUNC")
+	flag.BoolVar(&opts.printScript, "print-script", false, "print debugger command scripts to standard error and exit without invoking the debugger; functions are discovered using -funcs-log (e.g. the output of the printed function discovery script) or -funcs-source dwarf")
+	flag.BoolVar(&opts.reportUnhit, "report-unhit", false, "report traced functions whose breakpoints were never hit to standard error")
+	flag.StringVar(&opts.funcsSource, "funcs-source", "gdb", "source of function discovery (gdb to invoke the debugger backend, or dwarf to parse DWARF debug information of ELF and Mach-O executables directly; Mach-O executables always use dwarf with GDB)")
+	flag.BoolVar(&opts.mergeOverloads, "merge-overloads", false, "merge overloaded C++ functions by base name (i.e. function name without parameter list)")
+	flag.BoolVar(&opts.metadata, "metadata", false, "include trace provenance (binary, arguments, time, debugger version and function count) as graph label in DOT output")
+	flag.BoolVar(&opts.traceOpts.ResolveSymbols, "resolve-symbols", false, "resolve address-only caller frames (e.g. calls through vtables) by symbol lookup of the call site address (GDB only)")
+	flag.BoolVar(&skipCXA, "skip-cxa", false, "drop edges to and from compiler and runtime generated functions (e.g. _GLOBAL__sub_I_*, __cxa_*, __libc_*, _init and frame_dummy)")
+	flag.StringVar(&skip, "skip", "", "drop edges to and from functions whose name matches REGEX")
+	flag.BoolVar(&opts.noCache, "no-cache", false, "bypass the cache of discovered functions (stored under the user cache directory, keyed by the SHA-256 hash and modification time of the binary)")
+	flag.StringVar(&opts.traceOpts.Core, "core", "", "record the call chain at crash time from core dump FILE of the binary rather than launching it (GDB only)")
+	flag.BoolVar(&opts.components, "components", false, "group output by weakly connected components of the call graph; as DOT subgraphs, or one file per component when -o is a directory (e.g. -o out/)")
+	flag.StringVar(&opts.traceOpts.WorkingDir, "working-dir", "", "run the traced program in working directory DIR")
+	flag.Var((*stringsFlag)(&opts.traceOpts.StripPrefixes), "strip-prefix", "strip PREFIX from source file paths of functions and stack frames (e.g. /build of binaries compiled on a different machine; may be repeated)")
+	flag.Var((*stringsFlag)(&opts.traceOpts.Env), "env", "set environment variable KEY=VALUE of the traced program (may be repeated)")
+	flag.BoolVar(&opts.traceOpts.Threads, "threads", false, "record the thread of each breakpoint hit")
+	flag.BoolVar(&opts.traceOpts.Returns, "returns", false, "record returns of traced functions to their callers, drawn as dotted back-edges in DOT output (requires -gdb-python)")
+	flag.IntVar(&opts.traceOpts.HitsPerFunc, "hits-per-fn", 0, "record at most N hits of each breakpoint, after which the breakpoint is disabled (bounds traces of hot functions); 0 for no limit (GDB only)")
+	flag.BoolVar(&opts.colorByThread, "color-by-thread", false, "color edges by the thread which exercised them in DOT output (edges of multiple threads are black)")
+	flag.Var(&conditions, "condition", "only stop at breakpoint of function FUNC when condition EXPR holds, in FUNC:EXPR form (e.g. 'render:layer == 2'; may be repeated)")
+	flag.BoolVar(&opts.depths, "depths", false, "annotate nodes with their minimum call depth from the roots and lay out nodes of the same depth in the same rank in DOT output")
+	flag.BoolVar(&opts.tee, "tee", false, "write output to standard output in addition to the output file of -o")
+	flag.BoolVar(&opts.collapseTemplates, "collapse-templates", false, "merge C++ template instantiations by stripping template arguments from function names (e.g. vector<>::push_back)")
+	flag.Var(&renames, "rename", "rename node labels matching PATTERN in DOT output, in PATTERN=>REPLACEMENT form (e.g. 'std::__cxx11::basic_string<[^>]*>=>string'; may be repeated, applied in order)")
+	flag.Var(&graphAttrs, "graph-attr", "set default graph attribute KEY=VALUE in DOT output (e.g. 'splines=ortho'; may be repeated)")
+	flag.Var(&nodeAttrs, "node-attr", "set default node attribute KEY=VALUE in DOT output (e.g. 'fontname=Helvetica'; may be repeated)")
+	flag.Var(&edgeAttrs, "edge-attr", "set default edge attribute KEY=VALUE in DOT output (e.g. 'arrowsize=0.5'; may be repeated)")
+	flag.BoolVar(&opts.highlightLeaves, "highlight-leaves", false, "draw leaf functions (i.e. functions never observed calling other functions) as filled boxes in DOT output")
+	flag.BoolVar(&opts.strict, "strict", false, "fail if the debugger exits with a non-zero exit status, rather than outputting the partial call graph")
+	flag.StringVar(&heatPath, "heat", "", "color nodes from white to red based on per-function metrics (e.g. profiling samples) of CSV FILE of funcname,value records in DOT output")
+	flag.StringVar(&diffPath, "diff", "", "draw the call graph as a diff against the call graph of JSON FILE (as output by -format json) in DOT output; added edges are green, removed edges red and dashed, and common edges grey")
+	flag.StringVar(&opts.statsPath, "stats", "", "write call graph statistics (node, edge, root and leaf counts, max depth, cycles and top functions by in-degree and out-degree) to FILE; \"-\" for standard error")
+	// Command line arguments following "--" are passed to the traced program.
+	args := os.Args[1:]
+	for i, arg := range args {
+		if arg == "--" {
+			opts.traceOpts.Args = args[i+1:]
+			args = args[:i]
+			break
+		}
+	}
+	flag.CommandLine.Parse(args)
+	if showVersion {
+		printVersion(os.Stdout, opts)
+		return
+	}
+	if verbose {
+		debugLog.SetOutput(os.Stderr)
+		callgraph.SetDebugOutput(os.Stderr)
+	}
+	if len(include) > 0 {
+		re, err := regexp.Compile(include)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		opts.include = re
+	}
+	if len(excludePath) > 0 {
+		patterns, err := parsePatterns(excludePath)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		if len(exclude) > 0 {
+			patterns = append(patterns, exclude)
+		}
+		exclude = unionPatterns(patterns)
+	}
+	if len(exclude) > 0 {
+		re, err := regexp.Compile(exclude)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		opts.exclude = re
+	}
+	for _, rename := range renames {
+		parts := strings.SplitN(rename, "=>", 2)
+		if len(parts) != 2 {
+			log.Fatalf("invalid rename %q; expected PATTERN=>REPLACEMENT", rename)
+		}
+		re, err := regexp.Compile(parts[0])
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		opts.renames = append(opts.renames, callgraph.Rename{Pattern: re, Replacement: parts[1]})
+	}
+	for _, attr := range graphAttrs {
+		a, err := parseDOTAttr(attr)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		opts.graphAttrs = append(opts.graphAttrs, a)
+	}
+	for _, attr := range nodeAttrs {
+		a, err := parseDOTAttr(attr)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		opts.nodeAttrs = append(opts.nodeAttrs, a)
+	}
+	for _, attr := range edgeAttrs {
+		a, err := parseDOTAttr(attr)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		opts.edgeAttrs = append(opts.edgeAttrs, a)
+	}
+	if len(heatPath) > 0 {
+		heat, err := loadHeat(heatPath)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		opts.heat = heat
+	}
+	if len(diffPath) > 0 {
+		baseline, err := loadBaseline(diffPath)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		opts.baseline = baseline
+	}
+	if opts.output == "-" {
+		// Explicit standard output.
+		opts.output = ""
+	}
+	if opts.tee && len(opts.output) == 0 {
+		log.Fatal("invalid -tee; requires output file (e.g. -o graph.dot)")
+	}
+	for _, cond := range conditions {
+		name, expr, err := parseCondition(cond)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		if opts.traceOpts.Conditions == nil {
+			opts.traceOpts.Conditions = make(map[string]string)
+		}
+		opts.traceOpts.Conditions[name] = expr
+	}
+	for _, glob := range opts.files {
+		if _, err := path.Match(glob, ""); err != nil {
+			log.Fatalf("invalid glob pattern %q of -files; %v", glob, err)
+		}
+	}
+	for _, env := range opts.traceOpts.Env {
+		if !strings.Contains(env, "=") || strings.HasPrefix(env, "=") {
+			log.Fatalf("invalid environment variable %q; expected KEY=VALUE", env)
+		}
+	}
+	if dir := opts.traceOpts.WorkingDir; len(dir) > 0 {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			log.Fatalf("invalid working directory %q; no such directory", dir)
+		}
+	}
+	if len(opts.traceOpts.Stdin) > 0 {
+		// Resolve standard input relative to the current working directory,
+		// rather than to the working directory of the traced program.
+		stdin, err := filepath.Abs(opts.traceOpts.Stdin)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		opts.traceOpts.Stdin = stdin
+	}
+	if opts.showStderr {
+		opts.traceOpts.Stderr = os.Stderr
+	}
+	opts.progress = showProgress && !quiet
+	if skipCXA {
+		opts.traceOpts.Skip = append(opts.traceOpts.Skip, callgraph.CompilerGeneratedFuncs...)
+	}
+	if len(skip) > 0 {
+		re, err := regexp.Compile(skip)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		opts.traceOpts.Skip = append(opts.traceOpts.Skip, re)
+	}
+	if len(funcNames) > 0 {
+		for _, name := range strings.Split(funcNames, ",") {
+			opts.funcNames = append(opts.funcNames, strings.TrimSpace(name))
+		}
+	}
+	if len(runsPath) > 0 {
+		runs, err := parseRuns(runsPath)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		opts.runs = runs
+	}
+	binPaths := flag.Args()
+	if len(binPaths) == 0 && len(opts.gdbLog) > 0 {
+		// The binary executable is not required when parsing pre-captured GDB
+		// output.
+		binPaths = []string{""}
+	}
+	if len(binPaths) == 0 && opts.traceOpts.PID != 0 {
+		// The binary executable is located by GDB when attaching to a running
+		// process.
+		binPaths = []string{""}
+	}
+	if len(opts.traceOpts.Core) > 0 && len(binPaths) != 1 {
+		log.Fatalf("invalid number of binaries %d; -core requires exactly one binary executable", len(binPaths))
+	}
 	// Generate call graph by capturing trace of stack frames while debugging in
-	// GDB.
-	for _, binPath := range flag.Args() {
-		if err := genCallGraph(binPath, output); err != nil {
+	// GDB or LLDB.
+	if opts.merge {
+		if err := genCallGraph(binPaths, opts); err != nil {
+			log.Fatalf("%+v", err)
+		}
+		return
+	}
+	for _, binPath := range binPaths {
+		if err := genCallGraph([]string{binPath}, opts); err != nil {
 			log.Fatalf("%+v", err)
 		}
 	}
 }
 
-// genCallGraph generates a call graph by tracing the given binary exectuable.
-// The output is stored to the specified output path in Graphviz DOT format.
-func genCallGraph(binPath, output string) error {
-	fns, err := getFuncs(binPath)
-	if err != nil {
-		return errors.WithStack(err)
+// parseCondition parses the given breakpoint condition of FUNC:EXPR form into
+// function name and condition expression. The separator is the first colon not
+// part of a C++ scope operator ("::").
+//
+// Example:
+//
+//    "ns::render:layer == 2" -> "ns::render", "layer == 2"
+func parseCondition(cond string) (name, expr string, err error) {
+	for i := 0; i < len(cond); i++ {
+		if cond[i] != ':' {
+			continue
+		}
+		if i+1 < len(cond) && cond[i+1] == ':' {
+			// Skip scope operator.
+			i++
+			continue
+		}
+		name = strings.TrimSpace(cond[:i])
+		expr = strings.TrimSpace(cond[i+1:])
+		if len(name) == 0 || len(expr) == 0 {
+			break
+		}
+		return name, expr, nil
+	}
+	return "", "", errors.Errorf("invalid breakpoint condition %q; expected FUNC:EXPR", cond)
+}
+
+// dotAttrKeyRegexp matches valid DOT attribute names.
+var dotAttrKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseDOTAttr parses the given DOT attribute in KEY=VALUE form.
+//
+// Example:
+//
+//    "fontname=Helvetica Neue" -> "fontname", "Helvetica Neue"
+func parseDOTAttr(attr string) (callgraph.DOTAttr, error) {
+	parts := strings.SplitN(attr, "=", 2)
+	if len(parts) != 2 {
+		return callgraph.DOTAttr{}, errors.Errorf("invalid DOT attribute %q; expected KEY=VALUE", attr)
+	}
+	key := strings.TrimSpace(parts[0])
+	if !dotAttrKeyRegexp.MatchString(key) {
+		return callgraph.DOTAttr{}, errors.Errorf("invalid DOT attribute name %q of %q", key, attr)
+	}
+	return callgraph.DOTAttr{Key: key, Value: parts[1]}, nil
+}
+
+// stringsFlag is a repeatable command line flag of string values.
+type stringsFlag []string
+
+// String returns the string representation of the flag values.
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set appends the given flag value.
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// options specifies the command line options of the callgraph tool.
+type options struct {
+	// Debugger backend (gdb or lldb).
+	debugger string
+	// Path to GDB executable.
+	gdbPath string
+	// Register GDB breakpoints using a GDB Python script.
+	gdbPython bool
+	// Function after which to retrieve functions, including functions of
+	// shared libraries loaded at runtime; empty to retrieve functions at
+	// startup.
+	breakAfter string
+	// Merge the call graphs of multiple binary executables into one.
+	merge bool
+	// Print the standard error output of the debugger.
+	showStderr bool
+	// Report progress of traces to standard error.
+	progress bool
+	// Regular expression of functions to trace using the GDB rbreak command;
+	// empty to enumerate functions.
+	rbreak string
+	// Tracing options.
+	traceOpts callgraph.Options
+	// Runs of the traced program, each with its own command line arguments and
+	// standard input; the call graphs of all runs are merged.
+	runs []run
+	// Output path.
+	output string
+	// Output format.
+	format string
+	// Path to pre-captured GDB trace output; skips invoking GDB for tracing.
+	gdbLog string
+	// Print debugger command scripts to standard error and exit without
+	// tracing.
+	printScript bool
+	// Report traced functions whose breakpoints were never hit.
+	reportUnhit bool
+	// Path to pre-captured GDB function listing output; skips invoking GDB for
+	// function discovery.
+	funcsLog string
+	// Source of function discovery (gdb or dwarf).
+	funcsSource string
+	// Only instrument functions matching include (if non-nil).
+	include *regexp.Regexp
+	// Do not instrument functions matching exclude (if non-nil).
+	exclude *regexp.Regexp
+	// Only instrument functions defined in source files matching any of the
+	// glob patterns (if non-empty).
+	files []string
+	// Only instrument functions with the given function names (if non-empty).
+	funcNames []string
+	// Group nodes into clusters by source file in DOT output.
+	cluster bool
+	// Color nodes participating in cycles in DOT output.
+	highlightCycles bool
+	// Sort edges of DOT output for deterministic output.
+	sortEdges bool
+	// Maximum number of distinct callees per node in DOT output; 0 for no
+	// limit.
+	maxOut int
+	// Minimum call count of edges; edges observed fewer times are pruned.
+	minCount int
+	// Function name of the main function (i.e. the program entry), marked as
+	// root of the call graph; empty to not mark the main function.
+	main string
+	// Function name of root, under which callers outside of the traced
+	// functions are reparented; empty to keep the observed roots.
+	root string
+	// Omit callee arguments from edge labels in DOT output.
+	noArgs bool
+	// Include the number of calls of each edge in text output.
+	showCount bool
+	// Include the source location of function definitions in node labels of
+	// DOT output.
+	labelLocation bool
+	// Identify nodes by numeric node identifiers in DOT output.
+	nodeIDs bool
+	// Include caller arguments as edge sub-label in DOT output.
+	callerArgs bool
+	// Include non-debugging symbols as grey nodes in DOT output.
+	includeNonDebug bool
+	// Include callee source line as edge sub-label in DOT output.
+	showSrcLine bool
+	// URL scheme of node links to source location in DOT output.
+	urlScheme string
+	// Direction of graph layout in DOT output.
+	rankDir string
+	// Scale pen width of edges based on call count in DOT output.
+	weightEdges bool
+	// Include trace provenance as graph label in DOT output.
+	metadata bool
+	// Bypass the function cache.
+	noCache bool
+	// Group output by weakly connected components of the call graph; either as
+	// DOT subgraphs, or as one file per component if the output path is a
+	// directory.
+	components bool
+	// Color edges by thread in DOT output.
+	colorByThread bool
+	// Annotate nodes with their call depth in DOT output.
+	depths bool
+	// Write output to standard output in addition to the output file.
+	tee bool
+	// Renames of node labels in DOT output, applied in order.
+	renames []callgraph.Rename
+	// Draw leaf functions as filled boxes in DOT output.
+	highlightLeaves bool
+	// Fail if the debugger exits with a non-zero exit status, rather than
+	// outputting the partial call graph.
+	strict bool
+	// Per-function metrics used to color nodes in DOT output, keyed by function
+	// name; nil to disable.
+	heat map[string]float64
+	// Baseline call graph to diff against in DOT output; nil to disable.
+	baseline []callgraph.Edge
+	// Default attributes of the graph, nodes and edges in DOT output.
+	graphAttrs, nodeAttrs, edgeAttrs []callgraph.DOTAttr
+	// Path to output file of call graph statistics ("-" for standard error);
+	// empty to omit statistics.
+	statsPath string
+	// Only output functions reachable from the given entry function (if
+	// non-empty).
+	entry string
+	// Invert edges so that callees point to their callers.
+	reverse bool
+	// Merge overloaded C++ functions by base name.
+	mergeOverloads bool
+	// Merge C++ template instantiations by stripping template arguments.
+	collapseTemplates bool
+	// Only output the transitive callers of the given function (if non-empty),
+	// or its neighborhood if radius is non-negative.
+	focus string
+	// Maximum number of hops from the focused function in either direction; -1
+	// to output the transitive callers of the focused function.
+	radius int
+}
+
+// debuggerBackend is a debugger which is also capable of parsing pre-captured
+// debugger output.
+type debuggerBackend interface {
+	callgraph.Debugger
+	// ParseFuncs parses debug information about functions of the given
+	// pre-captured debugger output.
+	ParseFuncs(output string) ([]callgraph.Func, error)
+	// ParseTrace parses the call graph edges of the given pre-captured debugger
+	// trace output.
+	ParseTrace(output string, fns []callgraph.Func) ([]callgraph.Edge, error)
+	// FuncsScript returns the debugger command script used to retrieve debug
+	// information about functions.
+	FuncsScript() string
+	// TraceScript returns the debugger command script used to trace the call
+	// graph of the specified functions.
+	TraceScript(fns []callgraph.Func) string
+}
+
+// traceReaderParser is a debugger which is capable of parsing pre-captured
+// debugger trace output line by line.
+type traceReaderParser interface {
+	// ParseTraceReader parses the call graph edges of the pre-captured debugger
+	// trace output read from r.
+	ParseTraceReader(r io.Reader, fns []callgraph.Func) ([]callgraph.Edge, error)
+}
+
+// newDebugger returns a new debugger backend based on the given command line
+// options and tracing options.
+func newDebugger(opts options, traceOpts callgraph.Options) (debuggerBackend, error) {
+	switch opts.debugger {
+	case "gdb":
+		g := callgraph.NewGDB()
+		g.Path = opts.gdbPath
+		g.Python = opts.gdbPython
+		g.RBreak = opts.rbreak
+		g.BreakAfter = opts.breakAfter
+		g.Options = traceOpts
+		return g, nil
+	case "lldb":
+		l := callgraph.NewLLDB()
+		l.Options = traceOpts
+		return l, nil
+	default:
+		return nil, errors.Errorf("support for debugger backend %q not yet implemented", opts.debugger)
+	}
+}
+
+// genCallGraph generates a call graph by tracing the given binary exectuables.
+// The output is stored to the specified output path in the given output format
+// (dot, json, jsonl, mermaid, graphml, chrome, csv, gexf, plantuml, d3, tree
+// or text). The call graphs of multiple binaries are merged into one (see
+// -merge). With the jsonl output format, edges are streamed as they are traced
+// (see streamCallGraph).
+func genCallGraph(binPaths []string, opts options) error {
+	switch opts.format {
+	case "dot", "json", "jsonl", "mermaid", "graphml", "chrome", "csv", "gexf", "plantuml", "d3", "tree", "text":
+		// valid output format.
+	default:
+		return errors.Errorf("support for output format %q not yet implemented", opts.format)
+	}
+	if opts.components && opts.format != "dot" && !isOutputDir(opts.output) {
+		return errors.Errorf("-components requires DOT output format or output directory (e.g. -o out/) for output format %q", opts.format)
+	}
+	if len(opts.rbreak) > 0 {
+		if opts.debugger != "gdb" {
+			return errors.Errorf("-rbreak requires GDB debugger backend")
+		}
+		if opts.gdbPython {
+			return errors.Errorf("-rbreak cannot be combined with -gdb-python")
+		}
+		if len(opts.funcNames) > 0 || len(opts.files) > 0 || opts.include != nil || opts.exclude != nil || opts.reportUnhit {
+			return errors.Errorf("-rbreak cannot be combined with -funcs, -files, -include, -exclude, -exclude-file or -report-unhit")
+		}
+	}
+	if len(opts.breakAfter) > 0 {
+		if opts.debugger != "gdb" {
+			return errors.Errorf("-breakpoint-after requires GDB debugger backend")
+		}
+		if len(opts.rbreak) > 0 || opts.funcsSource != "gdb" {
+			return errors.Errorf("-breakpoint-after cannot be combined with -rbreak or -funcs-source %q", opts.funcsSource)
+		}
+	}
+	if opts.traceOpts.Returns {
+		if opts.debugger != "gdb" || !opts.gdbPython {
+			return errors.Errorf("-returns requires -gdb-python")
+		}
+		if opts.format != "dot" && opts.format != "json" && opts.format != "jsonl" {
+			return errors.Errorf("-returns requires DOT, JSON or JSON lines output format; got %q", opts.format)
+		}
+	}
+	if opts.showCount && opts.format != "text" {
+		return errors.Errorf("-show-count requires text output format; got %q", opts.format)
+	}
+	if opts.baseline != nil && opts.format != "dot" {
+		return errors.Errorf("-diff requires DOT output format; got %q", opts.format)
+	}
+	switch opts.funcsSource {
+	case "gdb", "dwarf":
+		// valid function discovery source.
+	default:
+		return errors.Errorf("invalid function discovery source %q; expected gdb or dwarf", opts.funcsSource)
+	}
+	switch opts.rankDir {
+	case "", "TB", "LR", "BT", "RL":
+		// valid rank direction.
+	default:
+		return errors.Errorf("invalid rank direction %q; expected TB, LR, BT or RL", opts.rankDir)
+	}
+	if opts.format == "jsonl" {
+		return streamCallGraph(binPaths, opts)
+	}
+	var (
+		edges  []callgraph.Edge
+		allFns []callgraph.Func
+		err    error
+	)
+	if len(binPaths) == 1 {
+		edges, allFns, err = traceBinary(binPaths[0], opts)
+	} else {
+		edges, allFns, err = traceBinaries(binPaths, opts)
 	}
-	edges, err := trace(binPath, fns)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	if opts.printScript {
+		return nil
+	}
+	// Notes of DOT nodes, keyed by function name.
+	nodeNotes := make(map[string]string)
+	if opts.collapseTemplates {
+		var instantiations map[string]int
+		edges, instantiations = callgraph.CollapseTemplates(edges)
+		for name, n := range instantiations {
+			nodeNotes[name] = fmt.Sprintf("%d instantiations", n)
+		}
+	}
+	if opts.mergeOverloads {
+		var overloads map[string]int
+		edges, overloads = callgraph.MergeOverloads(edges)
+		for name, n := range overloads {
+			note := fmt.Sprintf("%d overloads", n)
+			if prev, ok := nodeNotes[name]; ok {
+				note = prev + ", " + note
+			}
+			nodeNotes[name] = note
+		}
+	}
+	// Return edges are excluded when pruning and focusing the call graph, and
+	// only retained between functions of the resulting call graph.
+	edges, returns := callgraph.SplitReturns(edges)
+	if len(opts.root) > 0 {
+		edges = callgraph.Reroot(edges, opts.root)
+	}
+	if opts.minCount > 1 {
+		edges = callgraph.PruneMinCount(edges, opts.minCount)
+	}
+	if len(opts.entry) > 0 {
+		if edges, err = pruneEntry(edges, opts.entry); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(opts.focus) > 0 {
+		if opts.radius >= 0 {
+			edges = callgraph.Neighborhood(edges, opts.focus, opts.radius)
+		} else {
+			edges = callgraph.CallersOf(edges, opts.focus)
+		}
+	}
+	if len(opts.main) > 0 && len(opts.root) == 0 {
+		// The root of -root takes precedence.
+		edges = callgraph.MarkMain(edges, opts.main)
+	}
+	if opts.reverse {
+		edges = callgraph.InvertEdges(edges)
+	}
+	edges = append(edges, callgraph.ReturnsWithin(returns, edges)...)
+	g := &callGraph{
+		edges:     edges,
+		funcs:     allFns,
+		nodeNotes: nodeNotes,
+	}
+	if opts.metadata {
+		g.label = metadataLabel(strings.Join(binPaths, " "), allFns, opts)
+	}
+	if len(opts.statsPath) > 0 {
+		if err := writeStats(edges, opts.statsPath); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if opts.components && isOutputDir(opts.output) {
+		return writeComponents(g, opts)
+	}
+	if opts.format == "dot" {
+		if renderFormat := graphvizFormat(opts.output); len(renderFormat) > 0 {
+			// Render DOT output using Graphviz.
+			buf := &bytes.Buffer{}
+			if err := writeGraph(buf, g, opts); err != nil {
+				return errors.WithStack(err)
+			}
+			if opts.tee {
+				if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			return renderGraphviz(buf.String(), opts.output, renderFormat)
+		}
+	}
 	var w io.Writer
 	w = os.Stdout
-	if len(output) > 0 {
-		f, err := os.Create(output)
+	if len(opts.output) > 0 {
+		f, err := os.Create(opts.output)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 		defer f.Close()
 		w = f
+		if opts.tee {
+			// Write output to both file and standard output.
+			w = io.MultiWriter(f, os.Stdout)
+		}
 	}
-	buf := callGraphString(w, edges)
-	if _, err := fmt.Fprintln(w, buf); err != nil {
-		return errors.WithStack(err)
-	}
-	return nil
+	return writeGraph(w, g, opts)
 }
 
-// callGraphString returns a string representation of the given call graph in
-// Graphviz DOT format.
-func callGraphString(w io.Writer, edges []Edge) string {
-	buf := &bytes.Buffer{}
-	buf.WriteString("digraph {\n")
-	zero := StackFrame{}
-	for _, edge := range edges {
-		if edge.Src == zero {
-			// Caller information missing.
-			fmt.Fprintf(buf, "\t%q\n", edge.Dst.FuncName)
-			continue
+// traceBinary traces the given binary executable, and returns the edges of its
+// call graph and the debug information about its functions. No edges are
+// returned if -print-script is set, as the trace script is printed instead.
+func traceBinary(binPath string, opts options) ([]callgraph.Edge, []callgraph.Func, error) {
+	var progress *progressReporter
+	if opts.progress {
+		progress = &progressReporter{w: os.Stderr}
+		opts.traceOpts.OnProgress = progress.update
+	}
+	dbg, err := newDebugger(opts, opts.traceOpts)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if opts.printScript && opts.funcsSource == "gdb" && len(opts.rbreak) == 0 {
+		fmt.Fprintf(os.Stderr, "# function discovery script\n%s\n", strings.TrimSpace(dbg.FuncsScript()))
+	}
+	if opts.printScript && len(opts.funcsLog) == 0 && len(opts.gdbLog) == 0 && (opts.funcsSource == "gdb" || len(opts.rbreak) > 0 || len(opts.breakAfter) > 0) {
+		// Function discovery would invoke the debugger.
+		return nil, nil, errors.Errorf("-print-script requires -funcs-log (e.g. output of the function discovery script) or -funcs-source dwarf to discover functions without invoking the debugger")
+	}
+	var allFns []callgraph.Func
+	// Functions retrieved at runtime (see -rbreak and -breakpoint-after) are
+	// not cached, as they depend on more than the binary.
+	if opts.noCache || len(opts.funcsLog) > 0 || len(opts.gdbLog) > 0 || len(opts.rbreak) > 0 || len(opts.breakAfter) > 0 {
+		allFns, err = getFuncs(dbg, binPath, opts)
+	} else {
+		allFns, err = cachedFuncs(dbg, binPath, opts)
+	}
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	// Breakpoints may only be added to functions with debug information,
+	// except for the breakpoints added by rbreak.
+	fns := callgraph.DebugFuncs(allFns)
+	if len(opts.rbreak) > 0 {
+		fns = allFns
+	}
+	nonDebugSyms := callgraph.NonDebugSyms(allFns)
+	if len(opts.funcNames) > 0 && len(opts.gdbLog) == 0 {
+		fns, err = callgraph.SelectFuncs(fns, opts.funcNames)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
 		}
-		if len(edge.Dst.Args) > 0 {
-			args := "(" + edge.Dst.Args + ")"
-			fmt.Fprintf(buf, "\t%q -> %q [label=%q]\n", edge.Src.FuncName, edge.Dst.FuncName, args)
-		} else {
-			fmt.Fprintf(buf, "\t%q -> %q\n", edge.Src.FuncName, edge.Dst.FuncName)
+	}
+	if len(opts.files) > 0 && len(opts.gdbLog) == 0 {
+		n := len(fns)
+		fns, err = callgraph.FilterFuncsByFile(fns, opts.files)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		debugLog.Printf("instrumenting %d of %d functions", len(fns), n)
+	}
+	if opts.include != nil || opts.exclude != nil {
+		n := len(fns)
+		fns = callgraph.FilterFuncs(fns, opts.include, opts.exclude)
+		debugLog.Printf("instrumenting %d of %d functions", len(fns), n)
+	}
+	if len(opts.traceOpts.Conditions) > 0 && len(opts.gdbLog) == 0 {
+		// Validate that functions of breakpoint conditions are traced.
+		var condNames []string
+		for name := range opts.traceOpts.Conditions {
+			condNames = append(condNames, name)
+		}
+		sort.Strings(condNames)
+		if _, err := callgraph.SelectFuncs(fns, condNames); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid -condition")
+		}
+	}
+	if allFns != nil {
+		debugLog.Printf("%d functions instrumented, %d non-debug symbols skipped", len(fns), len(nonDebugSyms))
+	}
+	if opts.printScript {
+		fmt.Fprintf(os.Stderr, "# trace script\n%s\n", strings.TrimSpace(dbg.TraceScript(fns)))
+		return nil, allFns, nil
+	}
+	if progress != nil {
+		progress.total = len(fns)
+	}
+	edges, err := traceRuns(dbg, binPath, fns, opts)
+	if progress != nil {
+		progress.done()
+	}
+	if err != nil {
+		if !isPartial(err, opts) {
+			return nil, nil, errors.WithStack(err)
+		}
+		// Output graph of what executed before the timeout or debugger exit.
+		log.Printf("warning: %v; outputting partial call graph", err)
+	}
+	if len(edges) == 0 {
+		status := "debugger exited successfully"
+		if err != nil {
+			status = err.Error()
+		}
+		hint := "the traced program may have failed to start, or no traced function was called"
+		if !opts.showStderr {
+			hint += " (use -show-gdb-stderr for debugger diagnostics)"
 		}
+		log.Printf("warning: no call graph edges recorded of %q (%s); %s", binPath, status, hint)
 	}
-	buf.WriteString("}")
-	return buf.String()
+	if opts.reportUnhit {
+		unhit := callgraph.UnhitFuncs(fns, edges)
+		fmt.Fprintf(os.Stderr, "%d of %d traced functions never hit:\n", len(unhit), len(fns))
+		for _, fn := range unhit {
+			fmt.Fprintf(os.Stderr, "\t%s:%d: %s\n", fn.File, fn.Line, fn.Sig)
+		}
+	}
+	return edges, allFns, nil
 }
 
-// Edge in call graph.
-type Edge struct {
-	// Caller function.
-	Src StackFrame
-	// Callee function.
-	Dst StackFrame
-	// Source code of callee source line.
-	SrcLine string
+// traceBinaries traces the given binary executables, and returns the edges of
+// their merged call graph and the debug information about their functions.
+// Functions of the same name defined in different source files of different
+// binaries (e.g. "main") are qualified by binary name (e.g. "main (server)"),
+// so that they are not merged; functions defined in the same source file (e.g.
+// of a library linked into each binary) are merged.
+func traceBinaries(binPaths []string, opts options) ([]callgraph.Edge, []callgraph.Func, error) {
+	// Call graph and functions of each binary, in order of binPaths.
+	var binEdges [][]callgraph.Edge
+	var binFns [][]callgraph.Func
+	// Source files of each function name, keyed by function name.
+	files := make(map[string]map[string]bool)
+	for _, binPath := range binPaths {
+		edges, allFns, err := traceBinary(binPath, opts)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to trace %q", binPath)
+		}
+		binEdges = append(binEdges, edges)
+		binFns = append(binFns, allFns)
+		for _, fn := range callgraph.DebugFuncs(allFns) {
+			if files[fn.Name] == nil {
+				files[fn.Name] = make(map[string]bool)
+			}
+			files[fn.Name][fn.File] = true
+		}
+	}
+	var edges []callgraph.Edge
+	var allFns []callgraph.Func
+	// Tracks functions already added, keyed by qualified function name and
+	// source location.
+	type key struct {
+		name, file string
+		line       int
+	}
+	seen := make(map[key]bool)
+	for i, binPath := range binPaths {
+		binName := filepath.Base(binPath)
+		qualify := func(name string) string {
+			if len(files[name]) > 1 {
+				return fmt.Sprintf("%s (%s)", name, binName)
+			}
+			return name
+		}
+		for _, edge := range binEdges[i] {
+			edge.Src.FuncName = qualify(edge.Src.FuncName)
+			edge.Dst.FuncName = qualify(edge.Dst.FuncName)
+			edges = append(edges, edge)
+		}
+		for _, fn := range binFns[i] {
+			fn.Name = qualify(fn.Name)
+			k := key{name: fn.Name, file: fn.File, line: fn.Line}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			allFns = append(allFns, fn)
+		}
+	}
+	return edges, allFns, nil
 }
 
-// trace traces the call graph of the specified functions in the given binary
-// and returns the edges of the call graph.
-func trace(binPath string, fns []Func) ([]Edge, error) {
-	input := &bytes.Buffer{}
-	output := &bytes.Buffer{}
-	errbuf := &bytes.Buffer{}
-	fmt.Fprintf(input, "set width 0\n")
-	fmt.Fprintf(input, "set height 0\n")
-	fmt.Fprintf(input, "set verbose off\n")
-	// Add breakpoints.
-	for _, fn := range fns {
-		fmt.Fprintf(input, "break %s:%d\n", fn.File, fn.Line)
-	}
-	// Hook backtrace command for each breakpoint.
-	for i := range fns {
-		breakNr := i + 1
-		fmt.Fprintf(input, "commands %d\n", breakNr)
-		//fmt.Fprintf(input, "info args\n")
-		fmt.Fprintf(input, "backtrace 2\n")
-		fmt.Fprintf(input, "continue\n")
-		fmt.Fprintf(input, "end\n")
-	}
-	fmt.Fprintf(input, "run\n")
-	// Run GDB.
-	cmd := exec.Command("gdb", "-q", binPath)
-	cmd.Stdin = input
-	cmd.Stdout = output
-	cmd.Stderr = errbuf
-	if err := cmd.Run(); err != nil {
-		return nil, errors.Wrapf(err, "GDB error: %v", errbuf)
-	}
-	edges, err := parseEdges(output.String(), fns)
-	if err != nil {
-		return nil, errors.WithStack(err)
+// isOutputDir reports whether the given output path denotes a directory (i.e.
+// ends with a path separator or is an existing directory).
+func isOutputDir(outPath string) bool {
+	if len(outPath) == 0 {
+		return false
 	}
-	return edges, nil
+	if strings.HasSuffix(outPath, "/") || strings.HasSuffix(outPath, string(filepath.Separator)) {
+		return true
+	}
+	fi, err := os.Stat(outPath)
+	return err == nil && fi.IsDir()
 }
 
-// parseEdges parses call graph edges in the given GDB output.
-//
-// Example GDB output:
+// writeComponents writes each weakly connected component of the given call
+// graph to a separate file in the output directory specified by -o, in the
+// output format specified by -format.
 //
-//    Breakpoint 1, main (argc=1, argv=0x7fffffffe6a8) at test.c:11
-//    11      foo(23);
-//    #0  main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+// Example output file names:
 //
-//    Breakpoint 2, foo (n=23) at test.c:19
-//    19      bar(n);
-//    #0  foo (n=23) at test.c:19
-//    #1  0x0000555555555152 in main (argc=1, argv=0x7fffffffe6a8) at test.c:11
-//
-//    Breakpoint 3, bar (n=23) at test.c:25
-//    25      baz(n);
-//    #0  bar (n=23) at test.c:25
-//    #1  0x0000555555555171 in foo (n=23) at test.c:19
-//
-//    Breakpoint 4, baz (n=23) at test.c:31
-//    31      return;
-//    #0  baz (n=23) at test.c:31
-//    #1  0x0000555555555189 in bar (n=23) at test.c:25
-func parseEdges(s string, fns []Func) ([]Edge, error) {
-	const breakpointPrefix = "\nBreakpoint "
-	bps := strings.Split(s, breakpointPrefix)
-	bps = bps[1:] // skip preamble output e.g. "Reading symbols from ./test"
-	var edges []Edge
-	for _, bp := range bps {
-		lines := strings.Split(bp, "\n")
-		// Source code of callee source line.
-		srcLine := lines[1]
-		var sts []StackFrame
-		for _, line := range lines {
-			if !strings.HasPrefix(line, "#") {
-				continue
-			}
-			st, err := parseStrackTrace(line)
-			if err != nil {
-				return nil, errors.WithStack(err)
-			}
-			sts = append(sts, st)
+//    out/component_1.dot
+//    out/component_2.dot
+func writeComponents(g *callGraph, opts options) error {
+	if err := os.MkdirAll(opts.output, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	for i, comp := range callgraph.ConnectedComponents(g.edges) {
+		name := fmt.Sprintf("component_%d%s", i+1, formatExt(opts.format))
+		outPath := filepath.Join(opts.output, name)
+		f, err := os.Create(outPath)
+		if err != nil {
+			return errors.WithStack(err)
 		}
-		edge := Edge{}
-		switch len(sts) {
-		case 0:
-			log.Printf("unable to determine caller/callee of stack frame %q", bp)
-			continue
-		case 1:
-			edge.Dst = sts[0]
-		case 2:
-			edge.Dst = sts[0]
-			edge.Src = sts[1]
-		default: // > 2
-			for i := 0; i < len(sts); i++ {
-				dst := sts[i]
-				if dst.StackFrameNum != 0 {
-					log.Printf("invalid stack frame number; expected #0, got #%d", dst.StackFrameNum)
-					break
-				}
-				edge := Edge{
-					Dst: dst,
-				}
-				if i+1 < len(sts) {
-					src := sts[i+1]
-					if src.StackFrameNum != 0 {
-						edge.Src = src
-						i++
-					}
-				}
-				// TODO: handle srcLine?
-				edges = append(edges, edge)
-			}
-			continue
+		compGraph := *g
+		compGraph.edges = comp
+		if err := writeGraph(f, &compGraph, opts); err != nil {
+			f.Close()
+			return errors.WithStack(err)
 		}
-		// Source code of callee source line.
-		//
-		// Example:
-		//
-		//    25      baz(n);
-		lineNumPrefix := strconv.Itoa(edge.Dst.LineNum)
-		if strings.HasPrefix(srcLine, lineNumPrefix) {
-			edge.SrcLine = srcLine
+		if err := f.Close(); err != nil {
+			return errors.WithStack(err)
 		}
-		pretty.Logln("edge:", edge)
-		edges = append(edges, edge)
 	}
-	return edges, nil
+	return nil
 }
 
-// StackFrame records information about a stack frame line.
-type StackFrame struct {
-	// Stack frame number (e.g. #0).
-	StackFrameNum int
-	// Function name. Callee if (#0), otherwise caller.
-	FuncName string
-	// Function arguments.
-	Args string
-	// Source file name at function call site.
-	SrcFile string
-	// Line number at function call site.
-	LineNum int
+// writeStats writes statistics of the given call graph to the specified output
+// path, or to standard error if the output path is "-".
+func writeStats(edges []callgraph.Edge, path string) error {
+	stats := callgraph.GraphStats(edges)
+	if path == "-" {
+		return callgraph.WriteStats(os.Stderr, stats)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	if err := callgraph.WriteStats(f, stats); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
 }
 
-// parseStrackTrace parses the given stack frame line.
-//
-// Example stack frame lines:
-//
-//    "#0  foo (n=23) at test.c:19"
-//    "#1  0x0000555555555171 in foo (n=23) at test.c:19"
-//    "#1  0x56598d16 in CCritSect::CCritSect (this=0x5686a728 <sgMemCrit>) at ./src/storm.h:2079"
-//    "#1  0x5655c988 in _GLOBAL__sub_I_mainmenu.cpp ()"
-//    "#1  0x5655d176 in myDebugBreak () at src/appfat.cpp:87"
-func parseStrackTrace(line string) (StackFrame, error) {
-	re1 := regexp.MustCompile(`#([0-9]+)[ \t]+(0x[0-9A-Fa-f]+ in )?([^ ]+) [(]([^)]*)[)]( at ([^:]+):([0-9]+))?`)
-	if matches := re1.FindStringSubmatch(line); len(matches) > 0 {
-		// ["#0  foo (n=23) at test.c:19" "0" "" "foo" "n=23" " at test.c:19" "test.c" "19"]
-		// ["#1  0x0000555555555171 in foo (n=23) at test.c:19" "1" "0x0000555555555171 in " "foo" "n=23" " at test.c:19" "test.c" "19"]
-		// ["#1  0x56598d16 in CCritSect::CCritSect (this=0x5686a728 <sgMemCrit>) at ./src/storm.h:2079" "1" "0x56598d16 in " "CCritSect::CCritSect" "this=0x5686a728 <sgMemCrit>" " at ./src/storm.h:2079" "./src/storm.h" "2079"]
-		// ["#1  0x5655c988 in _GLOBAL__sub_I_mainmenu.cpp ()" "1" "0x5655c988 in " "_GLOBAL__sub_I_mainmenu.cpp" "" "" "" ""]
-		rawStackFrameNum := matches[1]
-		stackFrameNum, err := strconv.Atoi(rawStackFrameNum)
+// streamCallGraph traces the given binary executables, and writes each call
+// graph edge to the output in JSON lines format as soon as it is traced (e.g.
+// for live consumption of long-running traces). Edges are written before any
+// pruning, so options transforming the call graph (e.g. -focus and -min-count)
+// are not applied.
+func streamCallGraph(binPaths []string, opts options) error {
+	if opts.components || len(opts.statsPath) > 0 {
+		return errors.Errorf("-components and -stats cannot be combined with JSON lines output format")
+	}
+	var w io.Writer
+	w = os.Stdout
+	if len(opts.output) > 0 {
+		f, err := os.Create(opts.output)
 		if err != nil {
-			return StackFrame{}, errors.WithStack(err)
+			return errors.WithStack(err)
 		}
-		st := StackFrame{
-			StackFrameNum: stackFrameNum,
-			FuncName:      matches[3],
-			Args:          matches[4],
-			SrcFile:       matches[6],
+		defer f.Close()
+		w = f
+		if opts.tee {
+			// Write output to both file and standard output.
+			w = io.MultiWriter(f, os.Stdout)
 		}
-		rawLineNum := matches[7]
-		if len(rawLineNum) > 0 {
-			lineNum, err := strconv.Atoi(rawLineNum)
-			if err != nil {
-				return StackFrame{}, errors.WithStack(err)
-			}
-			st.LineNum = lineNum
+	}
+	// First error encountered while writing edges; subsequent edges are
+	// discarded.
+	var werr error
+	opts.traceOpts.OnEdge = func(edge callgraph.Edge) {
+		if werr != nil {
+			return
+		}
+		werr = callgraph.WriteJSONLine(w, edge)
+	}
+	for _, binPath := range binPaths {
+		if _, _, err := traceBinary(binPath, opts); err != nil {
+			return errors.WithStack(err)
+		}
+		if werr != nil {
+			return errors.WithStack(werr)
 		}
-		return st, nil
 	}
-	return StackFrame{}, errors.Errorf("unable to parse stack frame line %q", line)
+	return nil
 }
 
-// Func contains debug information about a function.
-type Func struct {
-	// Source code file path.
-	File string
-	// Line number in source code.
-	Line int
-	// Function signature.
-	Sig string
+// formatExt returns the file extension (e.g. ".dot") of the given output
+// format.
+func formatExt(format string) string {
+	switch format {
+	case "mermaid":
+		return ".mmd"
+	case "chrome", "d3":
+		return ".json"
+	case "plantuml":
+		return ".puml"
+	case "tree", "text":
+		return ".txt"
+	default:
+		return "." + format
+	}
 }
 
-// GDB command to retrieve debug information of function signatures.
-//
-// Example GDB output:
-//    All defined functions:
+// pruneEntry prunes the given call graph to the functions reachable from the
+// specified entry function.
+func pruneEntry(edges []callgraph.Edge, entry string) ([]callgraph.Edge, error) {
+	seen := false
+	for _, edge := range edges {
+		if edge.Src.FuncName == entry {
+			return callgraph.CalleesOf(edges, entry), nil
+		}
+		if edge.Dst.FuncName == entry {
+			seen = true
+		}
+	}
+	if seen {
+		return nil, errors.Errorf("entry function %q never calls any traced function; ensure its callees are instrumented (see -funcs, -include and -exclude)", entry)
+	}
+	return nil, errors.Errorf("entry function %q not present in trace; ensure it is instrumented and executed", entry)
+}
+
+// callGraph is a traced call graph with output annotations.
+type callGraph struct {
+	// Call graph edges.
+	edges []callgraph.Edge
+	// Debug information about functions of the traced binary.
+	funcs []callgraph.Func
+	// Notes of nodes in DOT output, keyed by function name.
+	nodeNotes map[string]string
+	// Graph label of DOT output; empty for no label.
+	label string
+}
+
+// writeGraph writes the given call graph to w in the output format specified
+// by -format.
+func writeGraph(w io.Writer, g *callGraph, opts options) error {
+	switch opts.format {
+	case "dot":
+		dotOpts := &callgraph.DOTOptions{
+			Cluster:         opts.cluster,
+			Funcs:           g.funcs,
+			HighlightCycles: opts.highlightCycles,
+			Sort:            opts.sortEdges,
+			MaxOut:          opts.maxOut,
+			NoArgs:          opts.noArgs,
+			LabelLocation:   opts.labelLocation,
+			NodeIDs:         opts.nodeIDs,
+			CallerArgs:      opts.callerArgs,
+			SrcLine:         opts.showSrcLine,
+			URLScheme:       opts.urlScheme,
+			Focus:           opts.focus,
+			Main:            opts.main,
+			RankDir:         opts.rankDir,
+			WeightEdges:     opts.weightEdges,
+			NodeNotes:       g.nodeNotes,
+			Label:           g.label,
+			Components:      opts.components,
+			ColorByThread:   opts.colorByThread,
+			Depths:          opts.depths,
+			Renames:         opts.renames,
+			HighlightLeaves: opts.highlightLeaves,
+			Heat:            opts.heat,
+			GraphAttrs:      opts.graphAttrs,
+			NodeAttrs:       opts.nodeAttrs,
+			EdgeAttrs:       opts.edgeAttrs,
+			Baseline:        opts.baseline,
+		}
+		if opts.includeNonDebug {
+			dotOpts.NonDebugSyms = callgraph.NonDebugSyms(g.funcs)
+		}
+		if err := callgraph.WriteDOT(w, g.edges, dotOpts); err != nil {
+			return errors.WithStack(err)
+		}
+	case "json":
+		if err := callgraph.WriteJSON(w, g.edges); err != nil {
+			return errors.WithStack(err)
+		}
+	case "mermaid":
+		if err := callgraph.WriteMermaid(w, g.edges); err != nil {
+			return errors.WithStack(err)
+		}
+	case "graphml":
+		if err := callgraph.WriteGraphML(w, g.edges); err != nil {
+			return errors.WithStack(err)
+		}
+	case "chrome":
+		if err := callgraph.WriteChrome(w, g.edges); err != nil {
+			return errors.WithStack(err)
+		}
+	case "csv":
+		if err := callgraph.WriteCSV(w, g.edges); err != nil {
+			return errors.WithStack(err)
+		}
+	case "gexf":
+		if err := callgraph.WriteGEXF(w, g.edges); err != nil {
+			return errors.WithStack(err)
+		}
+	case "plantuml":
+		if err := callgraph.WritePlantUML(w, g.edges); err != nil {
+			return errors.WithStack(err)
+		}
+	case "d3":
+		if err := callgraph.WriteD3(w, g.edges); err != nil {
+			return errors.WithStack(err)
+		}
+	case "tree":
+		if err := callgraph.WriteTree(w, g.edges); err != nil {
+			return errors.WithStack(err)
+		}
+	case "text":
+		if err := callgraph.WriteText(w, g.edges, opts.showCount); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// metadataLabel returns a graph label describing the provenance of the trace
+// of the given binary executable, including the command line arguments of the
+// traced program, the time of tracing, the debugger version and the number of
+// functions.
 //
-//    File test.c:
-//    9:    int main(int, char **);
-//    23:   static void bar(int);
-//    29:   static void baz(int);
-//    17:   static void foo(int);
+// Example:
 //
-//    Non-debugging symbols:
-//    0x0000000000001000  _init
-//    0x0000000000001030  exit@plt
-//    0x0000000000001040  _start
-//    0x0000000000001070  deregister_tm_clones
-//    0x00000000000010a0  register_tm_clones
-//    0x00000000000010e0  __do_global_dtors_aux
-//    0x0000000000001130  frame_dummy
-//    0x00000000000011a0  __libc_csu_init
-//    0x0000000000001210  __libc_csu_fini
-//    0x0000000000001218  _fini
-const gdbGetFuncs = `
-set width 0
-set height 0
-set verbose off
-info functions
-`
+//    traced: ./test 'foo' at 2024-03-01T12:00:00Z
+//    debugger: GNU gdb (GDB) 13.2
+//    functions: 4
+func metadataLabel(binPath string, allFns []callgraph.Func, opts options) string {
+	traced := binPath
+	if len(opts.gdbLog) > 0 {
+		traced = opts.gdbLog
+	}
+	for _, arg := range opts.traceOpts.Args {
+		traced += " " + strconv.Quote(arg)
+	}
+	var lines []string
+	lines = append(lines, fmt.Sprintf("traced: %s at %s", traced, time.Now().UTC().Format(time.RFC3339)))
+	lines = append(lines, fmt.Sprintf("debugger: %s", debuggerVersion(opts)))
+	lines = append(lines, fmt.Sprintf("functions: %d", len(callgraph.DebugFuncs(allFns))))
+	return strings.Join(lines, "\n")
+}
 
-// getFuncs retrieves debug information about functions of the given binary
-// executable.
-func getFuncs(binPath string) ([]Func, error) {
-	input := &bytes.Buffer{}
-	output := &bytes.Buffer{}
-	errbuf := &bytes.Buffer{}
-	input.WriteString(gdbGetFuncs)
-	cmd := exec.Command("gdb", "-q", binPath)
-	cmd.Stdin = input
-	cmd.Stdout = output
-	cmd.Stderr = errbuf
-	if err := cmd.Run(); err != nil {
-		return nil, errors.Wrapf(err, "GDB error: %v", errbuf)
-	}
-	fns, err := parseFuncs(output.String())
+// debuggerPath returns the path to the executable of the debugger backend.
+func debuggerPath(opts options) string {
+	if opts.debugger == "lldb" {
+		return callgraph.NewLLDB().Path
+	}
+	return opts.gdbPath
+}
+
+// debuggerVersion returns the version of the debugger backend, as reported by
+// the first output line of "--version"; or "unknown" if not available.
+func debuggerVersion(opts options) string {
+	output, err := exec.Command(debuggerPath(opts), "--version").Output()
+	if err != nil {
+		return "unknown"
+	}
+	version := strings.TrimSpace(string(output))
+	if pos := strings.Index(version, "\n"); pos != -1 {
+		version = version[:pos]
+	}
+	return version
+}
+
+// dwarfFuncs retrieves debug information about functions of the given binary
+// executable by parsing its DWARF debug information, with source file path
+// prefixes stripped as by the debugger backends (see -strip-prefix).
+func dwarfFuncs(binPath string, opts options) ([]callgraph.Func, error) {
+	fns, err := callgraph.DWARFFuncs(binPath)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	for i := range fns {
+		fns[i].File = callgraph.TrimSrcPrefix(fns[i].File, opts.traceOpts.StripPrefixes)
+	}
 	return fns, nil
 }
 
-// parseFuncs parses debug information about functions of the given GDB output.
-//
-// Example GDB output:
-//    All defined functions:
-//
-//    File test.c:
-//    9:    int main(int, char **);
-//    23:   static void bar(int);
-//    29:   static void baz(int);
-//    17:   static void foo(int);
-//
-//    Non-debugging symbols:
-//    0x0000000000001000  _init
-//    0x0000000000001030  exit@plt
-//    0x0000000000001040  _start
-//    0x0000000000001070  deregister_tm_clones
-//    0x00000000000010a0  register_tm_clones
-//    0x00000000000010e0  __do_global_dtors_aux
-//    0x0000000000001130  frame_dummy
-//    0x00000000000011a0  __libc_csu_init
-//    0x0000000000001210  __libc_csu_fini
-//    0x0000000000001218  _fini
-func parseFuncs(s string) ([]Func, error) {
-	const startPrefix = "All defined functions:"
-	start := strings.Index(s, startPrefix)
-	if start == -1 {
-		return nil, errors.Errorf("unable to find start position of defined functions; expected %q, got %q", startPrefix, s)
-	}
-	s = s[start:]
-	// Parse file functions.
-	lines := strings.Split(s, "\n")
-	// Current source code file name.
-	srcFile := ""
-	var fns []Func
-	for i := 0; i < len(lines); i++ {
-		line := lines[i]
-		// File test.c:
-		if strings.HasPrefix(line, "File ") && strings.HasSuffix(line, ":") {
-			srcFile = line[len("File ") : len(line)-len(":")]
-			continue
+// getFuncs retrieves debug information about functions of the given binary
+// executable, either by invoking the debugger, by parsing the DWARF debug
+// information of the executable (see -funcs-source), or by parsing the
+// pre-captured debugger function listing specified by -funcs-log.
+func getFuncs(dbg debuggerBackend, binPath string, opts options) ([]callgraph.Func, error) {
+	if len(opts.funcsLog) > 0 {
+		buf, err := ioutil.ReadFile(opts.funcsLog)
+		if err != nil {
+			return nil, errors.WithStack(err)
 		}
-		if len(line) == 0 {
-			srcFile = ""
-			continue
+		return dbg.ParseFuncs(string(buf))
+	}
+	if len(opts.gdbLog) > 0 {
+		// Function discovery is not required to parse pre-captured GDB trace
+		// output.
+		return nil, nil
+	}
+	if len(opts.rbreak) > 0 {
+		// Functions matching -rbreak, in order of the breakpoints added by
+		// rbreak.
+		return dbg.(*callgraph.GDB).RBreakFuncs(binPath)
+	}
+	if len(opts.breakAfter) > 0 {
+		// Functions of the traced program once it reaches -breakpoint-after,
+		// including functions of shared libraries loaded at runtime.
+		return dbg.Funcs(binPath)
+	}
+	if opts.funcsSource == "dwarf" {
+		// Parse DWARF debug information directly, without invoking the
+		// debugger.
+		return dwarfFuncs(binPath, opts)
+	}
+	if opts.debugger == "gdb" && len(binPath) > 0 {
+		// GDB is often unable to read the symbols of Mach-O binaries (e.g. on
+		// macOS); parse DWARF debug information directly instead. Skipped when
+		// attaching by PID without a binary, as GDB infers the binary from the
+		// process.
+		format, err := callgraph.DetectFormat(binPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
 		}
-		if len(srcFile) == 0 {
-			continue
+		if format == callgraph.FormatMachO || format == callgraph.FormatFatMachO {
+			debugLog.Printf("parsing DWARF debug information of Mach-O binary %q", binPath)
+			return dwarfFuncs(binPath, opts)
 		}
-		parts := strings.Split(line, ":")
-		// 9:	int main(int, char **);
-		if len(parts) == 2 {
-			rawLine := strings.TrimSpace(parts[0])
-			sig := strings.TrimSpace(parts[1])
-			line, err := strconv.Atoi(rawLine)
+	}
+	return dbg.Funcs(binPath)
+}
+
+// trace traces the call graph of the specified functions in the given binary,
+// either by invoking the debugger or by parsing the pre-captured debugger trace
+// output specified by -gdb-log.
+func trace(dbg debuggerBackend, binPath string, fns []callgraph.Func, opts options) ([]callgraph.Edge, error) {
+	if len(opts.gdbLog) > 0 {
+		if p, ok := dbg.(traceReaderParser); ok {
+			// Parse huge debugger trace output without reading it into memory.
+			f, err := os.Open(opts.gdbLog)
 			if err != nil {
 				return nil, errors.WithStack(err)
 			}
-			fn := Func{
-				File: srcFile,
-				Line: line,
-				Sig:  sig,
+			defer f.Close()
+			return p.ParseTraceReader(f, fns)
+		}
+		buf, err := ioutil.ReadFile(opts.gdbLog)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return dbg.ParseTrace(string(buf), fns)
+	}
+	return dbg.Trace(binPath, fns)
+}
+
+// isPartial reports whether the given trace error is accompanied by the call
+// graph edges of partial debugger output, which are output with a warning. With
+// -strict, a non-zero exit status of the debugger is a fatal error.
+func isPartial(err error, opts options) bool {
+	if opts.strict && errors.Cause(err) == callgraph.ErrExitStatus {
+		return false
+	}
+	return callgraph.IsPartial(err)
+}
+
+// traceRuns traces the call graph of the specified functions in the given
+// binary, once per run of the traced program as specified by -runs, and
+// returns the merged edges of all runs. Each edge records the run that
+// exercised it.
+func traceRuns(dbg debuggerBackend, binPath string, fns []callgraph.Func, opts options) ([]callgraph.Edge, error) {
+	if len(opts.runs) == 0 {
+		return trace(dbg, binPath, fns, opts)
+	}
+	var edges []callgraph.Edge
+	for i, r := range opts.runs {
+		traceOpts := opts.traceOpts
+		traceOpts.Args = r.args
+		traceOpts.Stdin = r.stdin
+		dbg, err := newDebugger(opts, traceOpts)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		runEdges, err := trace(dbg, binPath, fns, opts)
+		if err != nil {
+			if !isPartial(err, opts) {
+				return nil, errors.WithStack(err)
 			}
-			fns = append(fns, fn)
-		}
-	}
-	sort.Slice(fns, func(i, j int) bool {
-		a := fns[i]
-		b := fns[j]
-		switch {
-		case a.File < b.File:
-			return true
-		case a.File > b.File:
-			return false
-		// a.File == b.File:
-		default:
-			return a.Line < b.Line
-		}
-	})
-	return fns, nil
+			log.Printf("warning: run %d: %v; including partial call graph", i+1, err)
+		}
+		for j := range runEdges {
+			runEdges[j].Run = i
+		}
+		edges = append(edges, runEdges...)
+	}
+	return edges, nil
 }
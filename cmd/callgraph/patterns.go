@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// parsePatterns parses the given file listing regular expressions, one
+// regular expression per line. Blank lines and lines starting with '#' are
+// ignored.
+//
+// Example:
+//
+//    # Standard library and logging helpers.
+//    ^std::
+//    log_(debug|trace)
+func parsePatterns(path string) ([]string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var patterns []string
+	for i, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := regexp.Compile(line); err != nil {
+			return nil, errors.Wrapf(err, "%s:%d: invalid regular expression %q", path, i+1, line)
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// unionPatterns returns a regular expression matching any of the given regular
+// expressions.
+func unionPatterns(patterns []string) string {
+	var alts []string
+	for _, pattern := range patterns {
+		alts = append(alts, "(?:"+pattern+")")
+	}
+	return strings.Join(alts, "|")
+}
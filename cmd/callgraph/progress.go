@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mewrev/callgraph"
+)
+
+// progressInterval is the minimum interval between progress reports.
+const progressInterval = 100 * time.Millisecond
+
+// progressReporter reports the progress of traces to w, overwriting the
+// previous report on each update (see -progress).
+//
+// Example output:
+//
+//    breakpoints: 1024/4096 added, 0 hits
+//    breakpoints: 4096/4096 added, 183402 hits
+type progressReporter struct {
+	// Output writer (e.g. standard error).
+	w io.Writer
+	// Number of breakpoints to add; zero if unknown.
+	total int
+	// Progress of latest update.
+	progress callgraph.Progress
+	// Time of latest report.
+	last time.Time
+	// A report is pending termination by a newline.
+	pending bool
+}
+
+// update records the given progress of the current trace, and reports it
+// unless a report was made within the last progress interval.
+func (p *progressReporter) update(progress callgraph.Progress) {
+	p.progress = progress
+	if time.Since(p.last) < progressInterval {
+		return
+	}
+	p.report()
+}
+
+// report reports the progress of the latest update.
+func (p *progressReporter) report() {
+	added := fmt.Sprintf("%d", p.progress.Breakpoints)
+	if p.total > 0 {
+		added = fmt.Sprintf("%d/%d", p.progress.Breakpoints, p.total)
+	}
+	fmt.Fprintf(p.w, "\rbreakpoints: %s added, %d hits", added, p.progress.Hits)
+	p.last = time.Now()
+	p.pending = true
+}
+
+// done reports the final progress of the current trace, terminated by a
+// newline, so that subsequent output starts on a new line.
+func (p *progressReporter) done() {
+	if !p.pending {
+		return
+	}
+	p.report()
+	fmt.Fprintln(p.w)
+	p.pending = false
+	p.progress = callgraph.Progress{}
+}
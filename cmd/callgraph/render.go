@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// graphvizFormat returns the Graphviz output format (e.g. "svg") to render
+// DOT output into, based on the extension of the given output path, or the
+// empty string if the output should not be rendered.
+func graphvizFormat(outPath string) string {
+	switch ext := strings.ToLower(filepath.Ext(outPath)); ext {
+	case ".svg", ".png", ".pdf":
+		return ext[len("."):]
+	default:
+		return ""
+	}
+}
+
+// renderGraphviz renders the given DOT text using the Graphviz dot command,
+// storing the output in the specified output format (e.g. "svg") to outPath.
+// If Graphviz is not installed, the raw DOT text is stored to outPath with the
+// extension replaced by ".dot" instead.
+func renderGraphviz(dotText string, outPath, format string) error {
+	dotPath, err := exec.LookPath("dot")
+	if err != nil {
+		rawPath := strings.TrimSuffix(outPath, filepath.Ext(outPath)) + ".dot"
+		log.Printf("warning: unable to locate Graphviz dot command; writing raw DOT output to %q", rawPath)
+		if err := ioutil.WriteFile(rawPath, []byte(dotText), 0644); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	errbuf := &bytes.Buffer{}
+	cmd := exec.Command(dotPath, "-T"+format, "-o", outPath)
+	cmd.Stdin = strings.NewReader(dotText)
+	cmd.Stderr = errbuf
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "Graphviz error: %v", errbuf)
+	}
+	return nil
+}
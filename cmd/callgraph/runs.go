@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// run specifies a run of the traced program.
+type run struct {
+	// Command line arguments.
+	args []string
+	// Path to file supplied to standard input; empty for no input.
+	stdin string
+}
+
+// parseRuns parses the given file listing runs of the traced program, one run
+// per line. Each line lists the whitespace-separated command line arguments of
+// the run, and optionally a standard input redirect ("<FILE" or "< FILE"),
+// the relative path of which is resolved relative to the current working
+// directory.
+// Blank lines and lines starting with '#' are ignored.
+//
+// Example:
+//
+//    # Exercise both levels in headless mode.
+//    --headless level1.map
+//    --headless level2.map <moves.txt
+func parseRuns(path string) ([]run, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var runs []run
+	for i, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r run
+		fields := strings.Fields(line)
+		for j := 0; j < len(fields); j++ {
+			field := fields[j]
+			if !strings.HasPrefix(field, "<") {
+				r.args = append(r.args, field)
+				continue
+			}
+			r.stdin = field[len("<"):]
+			if len(r.stdin) == 0 && j+1 < len(fields) {
+				j++
+				r.stdin = fields[j]
+			}
+			if len(r.stdin) == 0 {
+				return nil, errors.Errorf("%s:%d: missing file name of standard input redirect", path, i+1)
+			}
+			// Resolve standard input relative to the current working directory,
+			// rather than to the working directory of the traced program.
+			stdin, err := filepath.Abs(r.stdin)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			r.stdin = stdin
+		}
+		runs = append(runs, r)
+	}
+	if len(runs) == 0 {
+		return nil, errors.Errorf("no runs listed in %q", path)
+	}
+	return runs, nil
+}
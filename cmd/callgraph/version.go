@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is the version of the callgraph tool; may be set at build time using
+// -ldflags "-X main.version=v1.2.3". Defaults to the module version of the
+// build information if unset.
+var version string
+
+// toolVersion returns the version of the callgraph tool; or "unknown" if not
+// available.
+func toolVersion() string {
+	if len(version) > 0 {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && len(info.Main.Version) > 0 {
+		// "(devel)" when built from a local checkout.
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// printVersion prints the version of the callgraph tool, the version of the
+// debugger backend and the Go version used to build the tool to w.
+//
+// Example output:
+//
+//    callgraph: v0.1.0
+//    debugger: GNU gdb (GDB) 12.1
+//    go: go1.14.15
+func printVersion(w io.Writer, opts options) {
+	fmt.Fprintf(w, "callgraph: %s\n", toolVersion())
+	fmt.Fprintf(w, "debugger: %s\n", debuggerVersion(opts))
+	fmt.Fprintf(w, "go: %s\n", runtime.Version())
+}
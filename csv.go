@@ -0,0 +1,44 @@
+package callgraph
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// WriteCSV writes the given call graph to w as an adjacency list in CSV format,
+// with one row per deduplicated edge. The caller column of edges with missing
+// caller information (i.e. roots) is left empty.
+//
+// Example output:
+//
+//    caller,callee,args,srcfile,line,count
+//    ,main,"argc=1, argv=0x7fffffffe6a8",test.c,11,1
+//    main,foo,n=23,test.c,19,1
+func WriteCSV(w io.Writer, edges []Edge) error {
+	cw := csv.NewWriter(w)
+	header := []string{"caller", "callee", "args", "srcfile", "line", "count"}
+	if err := cw.Write(header); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, edge := range CollapseEdges(edges) {
+		record := []string{
+			edge.Src.FuncName,
+			edge.Dst.FuncName,
+			edge.Dst.Args,
+			edge.Dst.SrcFile,
+			strconv.Itoa(edge.Dst.LineNum),
+			strconv.Itoa(edge.Count),
+		}
+		if err := cw.Write(record); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package callgraph
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// d3Graph is the JSON representation of a call graph, as used by d3-force.
+type d3Graph struct {
+	// Nodes of the call graph.
+	Nodes []d3Node `json:"nodes"`
+	// Links of the call graph.
+	Links []d3Link `json:"links"`
+}
+
+// d3Node is the JSON representation of a d3-force node.
+type d3Node struct {
+	// Function name.
+	ID string `json:"id"`
+	// Source file of function; empty if unknown.
+	File string `json:"file"`
+	// Group of node (for coloring); index of source file in order of first
+	// appearance, starting at 1, or 0 if the source file is unknown.
+	Group int `json:"group"`
+}
+
+// d3Link is the JSON representation of a d3-force link.
+type d3Link struct {
+	// Caller function name.
+	Source string `json:"source"`
+	// Callee function name.
+	Target string `json:"target"`
+	// Number of calls.
+	Value int `json:"value"`
+}
+
+// WriteD3 writes the given call graph to w in the JSON node-link format of
+// d3-force, with one link per unique edge.
+//
+// Example output:
+//
+//    {
+//       "nodes": [
+//          {"id": "main", "file": "test.c", "group": 1},
+//          {"id": "foo", "file": "test.c", "group": 1}
+//       ],
+//       "links": [
+//          {"source": "main", "target": "foo", "value": 1}
+//       ]
+//    }
+func WriteD3(w io.Writer, edges []Edge) error {
+	graph := d3Graph{
+		Nodes: []d3Node{},
+		Links: []d3Link{},
+	}
+	frames := FuncFrames(edges)
+	// Group of each source file, keyed by source file.
+	groups := make(map[string]int)
+	for _, name := range newNodeIDs(edges).names {
+		file := frames[name].SrcFile
+		group := 0
+		if len(file) > 0 {
+			if _, ok := groups[file]; !ok {
+				groups[file] = len(groups) + 1
+			}
+			group = groups[file]
+		}
+		node := d3Node{
+			ID:    name,
+			File:  file,
+			Group: group,
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	zero := StackFrame{}
+	for _, edge := range CollapseEdges(edges) {
+		if edge.Src == zero {
+			// Caller information missing; node already included.
+			continue
+		}
+		link := d3Link{
+			Source: edge.Src.FuncName,
+			Target: edge.Dst.FuncName,
+			Value:  edge.Count,
+		}
+		graph.Links = append(graph.Links, link)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(graph); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
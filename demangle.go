@@ -0,0 +1,72 @@
+package callgraph
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// demangleName returns the demangled version of the given symbol name if
+// demangling is enabled, and the name as-is otherwise.
+func (o *Options) demangleName(name string) string {
+	if !o.Demangle {
+		return name
+	}
+	return demangle(name)
+}
+
+var (
+	// demangled caches demangled symbol names, keyed by mangled name.
+	demangled = make(map[string]string)
+	// demangledMutex guards demangled.
+	demangledMutex sync.Mutex
+)
+
+// demangle returns the demangled version of the given C++ symbol name, as
+// reported by c++filt, without parameter list so that it matches the function
+// names of GDB stack frames. The name is returned as-is if it is not mangled or
+// if demangling fails.
+//
+// Examples:
+//
+//    _ZN9CCritSectC2Ev -> CCritSect::CCritSect
+//    _ZZNK1W4drawEvENKUliE_clEi -> W::draw() const::{lambda(int)#1}::operator()
+func demangle(name string) string {
+	// Itanium C++ ABI mangled names start with "_Z".
+	if !strings.HasPrefix(name, "_Z") {
+		return name
+	}
+	demangledMutex.Lock()
+	defer demangledMutex.Unlock()
+	if s, ok := demangled[name]; ok {
+		return s
+	}
+	s := name
+	if d, err := cxxfilt("-p", name); err == nil {
+		s = d
+	} else if d, err := cxxfilt(name); err == nil {
+		// c++filt lacking support for -p (omit parameter lists); strip the
+		// parameter list instead.
+		s = baseName(d)
+	}
+	demangled[name] = s
+	return s
+}
+
+// cxxfilt runs c++filt with the given arguments, and returns its output.
+func cxxfilt(args ...string) (string, error) {
+	output := &bytes.Buffer{}
+	cmd := exec.Command("c++filt", args...)
+	cmd.Stdout = output
+	if err := cmd.Run(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	d := strings.TrimSpace(output.String())
+	if len(d) == 0 {
+		return "", errors.New("empty c++filt output")
+	}
+	return d, nil
+}
@@ -0,0 +1,27 @@
+package callgraph
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestDemangle(t *testing.T) {
+	if _, err := exec.LookPath("c++filt"); err != nil {
+		t.Skip("unable to locate c++filt executable")
+	}
+	golden := []struct {
+		name string
+		want string
+	}{
+		{name: "main", want: "main"},
+		{name: "_ZN9CCritSectC2Ev", want: "CCritSect::CCritSect"},
+		{name: "_ZN2nslsEiNS_1VE", want: "ns::operator<<"},
+		{name: "_ZNK1W4drawEv", want: "W::draw"},
+		{name: "_ZZNK1W4drawEvENKUliE_clEi", want: "W::draw() const::{lambda(int)#1}::operator()"},
+	}
+	for _, g := range golden {
+		if got := demangle(g.name); got != g.want {
+			t.Errorf("%q: demangled name mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,861 @@
+package callgraph
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DOTOptions specifies the output options of the Graphviz DOT writer.
+type DOTOptions struct {
+	// Group nodes into clusters based on the source file of their function.
+	Cluster bool
+	// Debug information about functions, used to locate the source file of each
+	// function when clustering, and the definition of each function when
+	// labelling nodes with source locations.
+	Funcs []Func
+	// Color nodes participating in a cycle (i.e. recursion) red.
+	HighlightCycles bool
+	// Sort edges by caller, callee and callee arguments rather than execution
+	// order, so that the output is deterministic across traces of the same
+	// call graph.
+	Sort bool
+	// Maximum number of distinct callees drawn per node; calls to the less
+	// frequently called callees of a node are collapsed into a single edge to a
+	// synthetic "... (+K more)" node. Zero for no limit.
+	MaxOut int
+	// Omit callee arguments from edge labels (e.g. pointer arguments which
+	// differ between calls).
+	NoArgs bool
+	// Include caller arguments as an edge sub-label.
+	CallerArgs bool
+	// Include the source line of the callee as an edge sub-label.
+	SrcLine bool
+	// URL scheme (e.g. "file://" or "vscode://file/") of node links to the
+	// source location of each function; empty to omit node links.
+	URLScheme string
+	// Function name of focused node, which is drawn in bold; empty if no node
+	// is focused.
+	Focus string
+	// Function name of the main function (i.e. the program entry), which is
+	// drawn in bold with a double border; empty to not mark the main function.
+	Main string
+	// Direction of graph layout (TB, LR, BT or RL); empty for the default
+	// top-down layout.
+	RankDir string
+	// Scale the pen width of edges logarithmically based on call count.
+	WeightEdges bool
+	// Include the source location of the definition of each function (see
+	// Funcs) in node labels (e.g. "foo\n(test.c:17)").
+	LabelLocation bool
+	// Notes appended to the label of nodes (e.g. "3 overloads"), keyed by
+	// function name.
+	NodeNotes map[string]string
+	// Graph label (e.g. trace provenance); empty for no label.
+	Label string
+	// Group nodes into subgraphs based on the weakly connected components of
+	// the call graph (see ConnectedComponents).
+	Components bool
+	// Color edges based on the thread which exercised them (see
+	// StackFrame.Thread); edges exercised by multiple threads are drawn black.
+	ColorByThread bool
+	// Annotate nodes with their minimum call depth from the roots, and lay out
+	// nodes of the same call depth in the same rank (see CallDepths).
+	Depths bool
+	// Draw leaf functions (i.e. functions never observed calling other
+	// functions) as filled boxes.
+	HighlightLeaves bool
+	// Per-function metric (e.g. profiling samples), keyed by function name,
+	// mapped to a white to red gradient of node fill colors; functions without
+	// a metric are filled grey. A nil map disables fill colors.
+	Heat map[string]float64
+	// Renames applied in order to the function names of node labels (e.g. to
+	// shorten demangled C++ names); node identity is unaffected.
+	Renames []Rename
+	// Identify nodes by numeric node identifiers, declared once with the
+	// function name as node label, rather than repeating the function name of
+	// nodes in each edge (e.g. to reduce the output size of large call graphs).
+	NodeIDs bool
+	// Symbol names of non-debugging symbols (i.e. functions without debug
+	// information), included as grey nodes to indicate where tracing coverage
+	// stops.
+	NonDebugSyms []string
+	// Default attributes of the graph, nodes and edges (e.g. fonts, sizes and
+	// splines), written at the top of the graph; attributes of individual nodes
+	// and edges take precedence.
+	GraphAttrs, NodeAttrs, EdgeAttrs []DOTAttr
+	// Baseline call graph (e.g. a previous trace read by ReadJSON) to draw the
+	// call graph as a diff against (see DiffGraphs); added edges are drawn
+	// green, removed edges red and dashed, and common edges grey. A nil
+	// baseline disables the diff.
+	Baseline []Edge
+}
+
+// DOTAttr is a user-defined Graphviz DOT attribute.
+type DOTAttr struct {
+	// Attribute name (e.g. "fontname").
+	Key string
+	// Attribute value (e.g. "Helvetica"); quoted on output.
+	Value string
+}
+
+// Rename is a regular expression based rename of node labels.
+type Rename struct {
+	// Regular expression of function name.
+	Pattern *regexp.Regexp
+	// Replacement of each match, as used by regexp.ReplaceAllString.
+	Replacement string
+}
+
+// nodeLabel returns the node label of the given function name, with the
+// specified renames applied in order.
+func nodeLabel(name string, renames []Rename) string {
+	for _, rename := range renames {
+		name = rename.Pattern.ReplaceAllString(name, rename.Replacement)
+	}
+	return name
+}
+
+// WriteDOT writes the given call graph to w in Graphviz DOT format, based on
+// the given output options. A nil opts uses the default output options.
+func WriteDOT(w io.Writer, edges []Edge, opts *DOTOptions) error {
+	if opts == nil {
+		opts = &DOTOptions{}
+	}
+	return writeDOT(w, edges, opts)
+}
+
+// writeDOT writes the given call graph to w in Graphviz DOT format. Return
+// edges (see EdgeReturn) are drawn as dotted back-edges, which do not affect
+// the layout of the call graph.
+func writeDOT(w io.Writer, edges []Edge, opts *DOTOptions) error {
+	if opts.Sort {
+		edges = sortEdges(edges)
+	}
+	edges, returns := SplitReturns(edges)
+	// Edge attributes of the diff against the baseline call graph, keyed by
+	// caller/callee function name pair.
+	var diffAttrs map[edgeKey]dotAttrs
+	if opts.Baseline != nil {
+		edges, diffAttrs = diffEdges(opts.Baseline, edges)
+	}
+	// Write errors are retained by bw and reported on flush.
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph {\n")
+	if len(opts.RankDir) > 0 {
+		fmt.Fprintf(bw, "\trankdir=%s;\n", opts.RankDir)
+	}
+	if len(opts.Label) > 0 {
+		fmt.Fprintf(bw, "\tlabel=%q;\n", opts.Label)
+	}
+	// Default attributes.
+	//
+	// Example:
+	//
+	//    graph [splines="ortho"];
+	//    node [fontname="Helvetica", fontsize="10"];
+	writeDefaultAttrs(bw, "graph", opts.GraphAttrs)
+	writeDefaultAttrs(bw, "node", opts.NodeAttrs)
+	writeDefaultAttrs(bw, "edge", opts.EdgeAttrs)
+	var more map[string]int
+	if opts.MaxOut > 0 {
+		edges, more = limitOutEdges(edges, opts.MaxOut)
+	}
+	nodeAttrs := dotNodeAttrs(edges, opts)
+	for name, n := range more {
+		// Example:
+		//
+		//    "foo (+3 more)" [label="... (+3 more)", shape="plaintext"]
+		nodeAttrs[name] = dotAttrs{
+			{key: "label", val: fmt.Sprintf("... (+%d more)", n)},
+			{key: "shape", val: "plaintext"},
+		}
+	}
+	// Include focused node even if isolated, and non-debugging symbols.
+	var extra []string
+	if len(opts.Focus) > 0 {
+		extra = append(extra, opts.Focus)
+	}
+	extra = append(extra, opts.NonDebugSyms...)
+	// Numeric node identifiers, shared by node declarations and edges of the
+	// call graph; nil to identify nodes by function name.
+	var ids *nodeIDs
+	if opts.NodeIDs {
+		// Declare nodes of return edges, should they be missing from the call
+		// graph.
+		for _, edge := range returns {
+			extra = append(extra, edge.Src.FuncName, edge.Dst.FuncName)
+		}
+		ids = newNodeIDs(edges)
+		for _, name := range extra {
+			ids.add(name)
+		}
+		// Declare each node with its function name as node label.
+		//
+		// Example:
+		//
+		//    3 [label="foo"]
+		for _, name := range ids.names {
+			if !nodeAttrs[name].has("label") {
+				nodeAttrs[name] = append(nodeAttrs[name], dotAttr{key: "label", val: nodeLabel(name, opts.Renames)})
+			}
+		}
+	}
+	// Annotate edges with the number of runs exercising them when merging the
+	// call graphs of multiple runs.
+	multipleRuns := false
+	for _, edge := range edges {
+		if edge.Run != edges[0].Run {
+			multipleRuns = true
+			break
+		}
+	}
+	if opts.Components {
+		// Example:
+		//
+		//    subgraph cluster_component_0 {
+		//       label="component 1"
+		//       "main" -> "foo"
+		//    }
+		for i, comp := range ConnectedComponents(edges) {
+			fmt.Fprintf(bw, "\tsubgraph cluster_component_%d {\n", i)
+			fmt.Fprintf(bw, "\t\tlabel=%q\n", fmt.Sprintf("component %d", i+1))
+			clusterPrefix := fmt.Sprintf("cluster_%d_", i)
+			writeDOTGraph(bw, comp, nil, ids, nodeAttrs, diffAttrs, multipleRuns, opts, "\t\t", clusterPrefix)
+			bw.WriteString("\t}\n")
+		}
+		// Isolated nodes outside of components.
+		frames := FuncFrames(edges)
+		for _, name := range extra {
+			if _, ok := frames[name]; !ok {
+				fmt.Fprintf(bw, "\t%s%s\n", dotNode(ids, name), nodeAttrs[name])
+			}
+		}
+	} else {
+		writeDOTGraph(bw, edges, extra, ids, nodeAttrs, diffAttrs, multipleRuns, opts, "\t", "cluster_")
+	}
+	writeReturns(bw, returns, ids)
+	if opts.Depths {
+		writeDepthRanks(bw, edges, ids)
+	}
+	bw.WriteString("}\n")
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// writeDOTGraph writes the node declarations and edges of the given call graph
+// to w, with nodes identified by refs (see dotNode) and edgeAttrs added to
+// matching edges. Extra function names are declared as nodes even if isolated;
+// lines are prefixed by indent and clusters are named using clusterPrefix.
+func writeDOTGraph(w *bufio.Writer, edges []Edge, extra []string, refs *nodeIDs, nodeAttrs map[string]dotAttrs, edgeAttrs map[edgeKey]dotAttrs, multipleRuns bool, opts *DOTOptions, indent, clusterPrefix string) {
+	ids := newNodeIDs(edges)
+	for _, name := range extra {
+		ids.add(name)
+	}
+	if opts.Cluster {
+		writeClusters(w, ids.names, FuncFrames(edges), opts.Funcs, refs, nodeAttrs, indent, clusterPrefix)
+	} else {
+		for _, name := range ids.names {
+			if attrs := nodeAttrs[name]; len(attrs) > 0 {
+				fmt.Fprintf(w, "%s%s%s\n", indent, dotNode(refs, name), attrs)
+			}
+		}
+	}
+	// Threads exercising each edge, keyed by caller/callee function name pair.
+	edgeThreads := make(map[edgeKey]map[int]bool)
+	if opts.ColorByThread {
+		for _, edge := range edges {
+			k := edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}
+			if edgeThreads[k] == nil {
+				edgeThreads[k] = make(map[int]bool)
+			}
+			edgeThreads[k][edge.Dst.Thread] = true
+		}
+	}
+	zero := StackFrame{}
+	for _, edge := range CollapseEdges(edges) {
+		if edge.Src == zero {
+			// Caller information missing.
+			fmt.Fprintf(w, "%s%s\n", indent, dotNode(refs, edge.Dst.FuncName))
+			continue
+		}
+		var labels []string
+		if len(edge.Dst.Args) > 0 && !opts.NoArgs {
+			args := "(" + edge.Dst.Args + ")"
+			labels = append(labels, args)
+		}
+		if edge.Count > 1 {
+			count := fmt.Sprintf("×%d", edge.Count)
+			labels = append(labels, count)
+		}
+		if multipleRuns {
+			runs := fmt.Sprintf("runs=%d", edge.Runs)
+			labels = append(labels, runs)
+		}
+		var attrs dotAttrs
+		srcLine := ""
+		if opts.SrcLine {
+			srcLine = srcLineLabel(edge.Edge)
+		}
+		if len(labels) > 0 || len(srcLine) > 0 || opts.CallerArgs {
+			label := strings.Join(labels, " ")
+			if len(srcLine) > 0 {
+				// Source line as sub-label. Quotes and backslashes of the source
+				// line are escaped when quoting the label attribute.
+				//
+				// Example:
+				//
+				//    (n=23)
+				//    test.c:25: baz(n);
+				if len(label) > 0 {
+					label += "\n"
+				}
+				label += srcLine
+			}
+			if opts.CallerArgs {
+				// Caller arguments as sub-label.
+				//
+				// Example:
+				//
+				//    (n=23)
+				//    from main(argc=1, argv=0x7fffffffe6a8)
+				callerArgs := fmt.Sprintf("from %s(%s)", edge.Src.FuncName, edge.Src.Args)
+				if len(label) > 0 {
+					label += "\n"
+				}
+				label += callerArgs
+			}
+			attrs = append(attrs, dotAttr{key: "label", val: label})
+		}
+		if edge.Dst.Inlined {
+			// Callee inlined into caller.
+			attrs = append(attrs, dotAttr{key: "style", val: "dashed"})
+		}
+		if opts.WeightEdges {
+			// Example:
+			//
+			//    penwidth="3.3"
+			penwidth := 1 + math.Log(float64(edge.Count))
+			attrs = append(attrs, dotAttr{key: "penwidth", val: fmt.Sprintf("%.1f", penwidth)})
+		}
+		if opts.ColorByThread {
+			if color := threadColor(edgeThreads[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}]); len(color) > 0 {
+				attrs = append(attrs, dotAttr{key: "color", val: color})
+			}
+		}
+		attrs = append(attrs, edgeAttrs[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}]...)
+		fmt.Fprintf(w, "%s%s -> %s%s\n", indent, dotNode(refs, edge.Src.FuncName), dotNode(refs, edge.Dst.FuncName), attrs)
+	}
+}
+
+// diffEdges returns the given call graph extended with the call edges removed
+// from the baseline call graph, and the edge attributes of the diff between the
+// call graphs, keyed by caller/callee function name pair.
+//
+// Example:
+//
+//    "main" -> "foo" [color="grey"]
+//    "foo" -> "baz" [color="green"]
+//    "foo" -> "bar" [color="red", style="dashed"]
+func diffEdges(baseline, edges []Edge) ([]Edge, map[edgeKey]dotAttrs) {
+	baseline, _ = SplitReturns(baseline)
+	added, removed, common := DiffGraphs(baseline, edges)
+	attrs := make(map[edgeKey]dotAttrs)
+	for _, edge := range added {
+		attrs[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}] = dotAttrs{{key: "color", val: "green"}}
+	}
+	for _, edge := range removed {
+		attrs[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}] = dotAttrs{{key: "color", val: "red"}, {key: "style", val: "dashed"}}
+	}
+	for _, edge := range common {
+		attrs[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}] = dotAttrs{{key: "color", val: "grey"}}
+	}
+	return append(edges, removed...), attrs
+}
+
+// limitOutEdges limits the number of distinct callees of each caller of the
+// given call graph to n, keeping the n most frequently called callees (by edge
+// count). The calls to the remaining callees of a caller are replaced by a
+// single call to a synthetic node. The number of collapsed callees is returned,
+// keyed by synthetic node name.
+func limitOutEdges(edges []Edge, n int) ([]Edge, map[string]int) {
+	zero := StackFrame{}
+	// Distinct callees of each caller, in order of first occurrence.
+	callees := make(map[string][]CountedEdge)
+	for _, edge := range CollapseEdges(edges) {
+		if edge.Src == zero {
+			continue
+		}
+		callees[edge.Src.FuncName] = append(callees[edge.Src.FuncName], edge)
+	}
+	// Collapsed caller/callee pairs.
+	collapsed := make(map[edgeKey]bool)
+	// Synthetic node name of each caller with collapsed callees.
+	moreNames := make(map[string]string)
+	more := make(map[string]int)
+	for caller, counted := range callees {
+		if len(counted) <= n {
+			continue
+		}
+		sort.SliceStable(counted, func(i, j int) bool {
+			return counted[i].Count > counted[j].Count
+		})
+		for _, edge := range counted[n:] {
+			collapsed[edgeKey{src: caller, dst: edge.Dst.FuncName}] = true
+		}
+		name := fmt.Sprintf("%s (+%d more)", caller, len(counted)-n)
+		moreNames[caller] = name
+		more[name] = len(counted) - n
+	}
+	if len(more) == 0 {
+		return edges, nil
+	}
+	var limited []Edge
+	// Callers for which a call to the synthetic node has been added.
+	added := make(map[string]bool)
+	for _, edge := range edges {
+		if edge.Src == zero || !collapsed[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}] {
+			limited = append(limited, edge)
+			continue
+		}
+		if added[edge.Src.FuncName] {
+			continue
+		}
+		added[edge.Src.FuncName] = true
+		moreEdge := Edge{
+			Src:   edge.Src,
+			Dst:   StackFrame{FuncName: moreNames[edge.Src.FuncName]},
+			Run:   edge.Run,
+			Index: edge.Index,
+		}
+		limited = append(limited, moreEdge)
+	}
+	return limited, more
+}
+
+// sortEdges returns a copy of the given edges, stably sorted by caller function
+// name, callee function name and callee arguments.
+func sortEdges(edges []Edge) []Edge {
+	sorted := make([]Edge, len(edges))
+	copy(sorted, edges)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Src.FuncName != b.Src.FuncName {
+			return a.Src.FuncName < b.Src.FuncName
+		}
+		if a.Dst.FuncName != b.Dst.FuncName {
+			return a.Dst.FuncName < b.Dst.FuncName
+		}
+		return a.Dst.Args < b.Dst.Args
+	})
+	return sorted
+}
+
+// writeReturns writes the given return edges to w, collapsed and annotated
+// with the number of returns.
+//
+// Example output:
+//
+//    "bar" -> "foo" [style="dotted", arrowhead="empty", constraint="false", label="×2"]
+func writeReturns(w *bufio.Writer, returns []Edge, ids *nodeIDs) {
+	for _, edge := range CollapseEdges(returns) {
+		attrs := dotAttrs{
+			{key: "style", val: "dotted"},
+			{key: "arrowhead", val: "empty"},
+			{key: "constraint", val: "false"},
+		}
+		if edge.Count > 1 {
+			attrs = append(attrs, dotAttr{key: "label", val: fmt.Sprintf("×%d", edge.Count)})
+		}
+		fmt.Fprintf(w, "\t%s -> %s%s\n", dotNode(ids, edge.Src.FuncName), dotNode(ids, edge.Dst.FuncName), attrs)
+	}
+}
+
+// writeDepthRanks writes rank constraints to w, so that nodes of the same
+// call depth are laid out in the same rank (see CallDepths).
+//
+// Example output:
+//
+//    {rank=same; "foo"; "qux";}
+func writeDepthRanks(w *bufio.Writer, edges []Edge, ids *nodeIDs) {
+	depths := CallDepths(edges)
+	// Function names of each call depth, keyed by call depth.
+	ranks := make(map[int][]string)
+	maxDepth := -1
+	for _, name := range newNodeIDs(edges).names {
+		depth, ok := depths[name]
+		if !ok {
+			continue
+		}
+		ranks[depth] = append(ranks[depth], name)
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	for depth := 0; depth <= maxDepth; depth++ {
+		names := ranks[depth]
+		if len(names) == 0 {
+			continue
+		}
+		w.WriteString("\t{rank=same;")
+		for _, name := range names {
+			fmt.Fprintf(w, " %s;", dotNode(ids, name))
+		}
+		w.WriteString("}\n")
+	}
+}
+
+// heatColor returns the fill color of the given metric value, on a gradient
+// from white (min) to red (max).
+//
+// Example:
+//
+//    heatColor(75, 0, 100) -> "#FF4040"
+func heatColor(v, min, max float64) string {
+	t := 1.0
+	if max > min {
+		t = (v - min) / (max - min)
+	}
+	t = math.Max(0, math.Min(1, t))
+	gb := int(math.Round(255 * (1 - t)))
+	return fmt.Sprintf("#FF%02X%02X", gb, gb)
+}
+
+// edgeKey is a caller/callee function name pair.
+type edgeKey struct {
+	src, dst string
+}
+
+// threadColors specifies the edge colors of threads, indexed by thread number
+// modulo the number of colors.
+var threadColors = []string{"blue", "red", "darkgreen", "orange", "purple", "brown", "cyan4", "magenta"}
+
+// threadColor returns the edge color of the given set of threads exercising an
+// edge; black if the edge was exercised by multiple threads, or the empty
+// string if the thread is unknown.
+func threadColor(threads map[int]bool) string {
+	if len(threads) != 1 {
+		return "black"
+	}
+	for thread := range threads {
+		if thread == 0 {
+			return ""
+		}
+		return threadColors[(thread-1)%len(threadColors)]
+	}
+	return ""
+}
+
+// srcLineLabel returns the trimmed source line of the callee of the given edge,
+// prefixed by the source file and line number of the callee, or the empty string
+// if the source line is unknown.
+//
+// Example:
+//
+//    "25      baz(n);" -> "test.c:25: baz(n);"
+func srcLineLabel(edge Edge) string {
+	// Trim line number prefix.
+	srcLine := strings.TrimLeft(edge.SrcLine, "0123456789")
+	srcLine = strings.TrimSpace(srcLine)
+	if len(srcLine) == 0 {
+		return ""
+	}
+	if len(edge.Dst.SrcFile) == 0 {
+		return srcLine
+	}
+	return fmt.Sprintf("%s:%d: %s", edge.Dst.SrcFile, edge.Dst.LineNum, srcLine)
+}
+
+// dotAttr is a Graphviz DOT attribute.
+type dotAttr struct {
+	// Attribute name.
+	key string
+	// Attribute value.
+	val string
+}
+
+// dotAttrs is a Graphviz DOT attribute list.
+type dotAttrs []dotAttr
+
+// String returns the string representation of the attribute list, preceded by
+// a space (e.g. ` [color="red", label="foo"]`), or the empty string if the list
+// is empty.
+func (attrs dotAttrs) String() string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString(" [")
+	for i, attr := range attrs {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(buf, "%s=%q", attr.key, attr.val)
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+// writeDefaultAttrs writes the given default attributes of the specified kind
+// of statement (graph, node or edge) to w, if any.
+func writeDefaultAttrs(w *bufio.Writer, kind string, attrs []DOTAttr) {
+	if len(attrs) == 0 {
+		return
+	}
+	var list dotAttrs
+	for _, attr := range attrs {
+		list = append(list, dotAttr{key: attr.Key, val: attr.Value})
+	}
+	fmt.Fprintf(w, "\t%s%s;\n", kind, list)
+}
+
+// dotNode returns the DOT node identifier of the given function name; the
+// numeric node identifier if ids is non-nil (see DOTOptions.NodeIDs), and the
+// quoted function name otherwise.
+//
+// Examples:
+//
+//    "foo"
+//    3
+func dotNode(ids *nodeIDs, name string) string {
+	if ids == nil {
+		return strconv.Quote(name)
+	}
+	return strconv.Itoa(ids.index[name])
+}
+
+// has reports whether the attribute list contains an attribute with the given
+// key.
+func (attrs dotAttrs) has(key string) bool {
+	for _, attr := range attrs {
+		if attr.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// withStyle returns the attribute list with the given style added to its style
+// attribute, as Graphviz only honours the last of duplicate attributes. Styles
+// are combined into a single comma-separated style attribute (e.g.
+// style="filled,bold").
+func (attrs dotAttrs) withStyle(style string) dotAttrs {
+	for i, attr := range attrs {
+		if attr.key != "style" {
+			continue
+		}
+		for _, s := range strings.Split(attr.val, ",") {
+			if s == style {
+				return attrs
+			}
+		}
+		attrs[i].val += "," + style
+		return attrs
+	}
+	return append(attrs, dotAttr{key: "style", val: style})
+}
+
+// dotNodeAttrs returns the DOT attributes of each node in the given call graph,
+// keyed by function name, based on the given output options.
+func dotNodeAttrs(edges []Edge, opts *DOTOptions) map[string]dotAttrs {
+	nodeAttrs := make(map[string]dotAttrs)
+	// Source location, and fan-in and fan-out of each node.
+	//
+	// Example:
+	//
+	//    test.c:19
+	//    in=1 out=1
+	frames := FuncFrames(edges)
+	for name, degree := range Degrees(edges) {
+		tooltip := fmt.Sprintf("in=%d out=%d", degree.In, degree.Out)
+		frame := frames[name]
+		if len(frame.SrcFile) > 0 {
+			tooltip = fmt.Sprintf("%s:%d\n%s", frame.SrcFile, frame.LineNum, tooltip)
+		}
+		nodeAttrs[name] = append(nodeAttrs[name], dotAttr{key: "tooltip", val: tooltip})
+		if len(opts.URLScheme) > 0 && len(frame.SrcFile) > 0 {
+			// Link to source location (e.g. "vscode://file/test.c:19").
+			url := fmt.Sprintf("%s%s:%d", opts.URLScheme, frame.SrcFile, frame.LineNum)
+			nodeAttrs[name] = append(nodeAttrs[name], dotAttr{key: "URL", val: url})
+		}
+	}
+	// Node label annotations, keyed by function name.
+	//
+	// Example:
+	//
+	//    foo
+	//    (test.c:17)
+	//    (3 overloads)
+	//    depth 2
+	notes := make(map[string][]string)
+	if opts.LabelLocation {
+		locs := funcLocations(opts.Funcs)
+		for name := range Degrees(edges) {
+			if loc, ok := locs[name]; ok {
+				notes[name] = append(notes[name], fmt.Sprintf("(%s)", loc))
+			}
+		}
+	}
+	for name, note := range opts.NodeNotes {
+		notes[name] = append(notes[name], fmt.Sprintf("(%s)", note))
+	}
+	if opts.Depths {
+		for name, depth := range CallDepths(edges) {
+			notes[name] = append(notes[name], fmt.Sprintf("depth %d", depth))
+		}
+	}
+	if len(opts.Renames) > 0 {
+		for name := range Degrees(edges) {
+			if _, ok := notes[name]; !ok && nodeLabel(name, opts.Renames) != name {
+				notes[name] = nil
+			}
+		}
+	}
+	for name, lines := range notes {
+		label := strings.Join(append([]string{nodeLabel(name, opts.Renames)}, lines...), "\n")
+		nodeAttrs[name] = append(nodeAttrs[name], dotAttr{key: "label", val: label})
+	}
+	if opts.HighlightCycles {
+		for _, cycle := range FindCycles(edges) {
+			for _, name := range cycle {
+				nodeAttrs[name] = append(nodeAttrs[name], dotAttr{key: "color", val: "red"})
+			}
+		}
+	}
+	if opts.Heat != nil {
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, v := range opts.Heat {
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+		for name := range Degrees(edges) {
+			color := "lightgrey"
+			if v, ok := opts.Heat[name]; ok {
+				color = heatColor(v, min, max)
+			}
+			nodeAttrs[name] = append(nodeAttrs[name].withStyle("filled"), dotAttr{key: "fillcolor", val: color})
+		}
+	}
+	if opts.HighlightLeaves {
+		for _, name := range leafFunctions(edges) {
+			nodeAttrs[name] = append(nodeAttrs[name], dotAttr{key: "shape", val: "box"}).withStyle("filled")
+		}
+	}
+	if len(opts.Focus) > 0 {
+		nodeAttrs[opts.Focus] = append(nodeAttrs[opts.Focus].withStyle("bold"), dotAttr{key: "penwidth", val: "2"})
+	}
+	if len(opts.Main) > 0 {
+		if _, ok := Degrees(edges)[opts.Main]; ok {
+			nodeAttrs[opts.Main] = append(nodeAttrs[opts.Main].withStyle("bold"), dotAttr{key: "peripheries", val: "2"})
+		}
+	}
+	for _, sym := range opts.NonDebugSyms {
+		nodeAttrs[sym] = append(nodeAttrs[sym], dotAttr{key: "color", val: "grey"}, dotAttr{key: "fontcolor", val: "grey"})
+	}
+	return nodeAttrs
+}
+
+// funcLocations returns the source location (e.g. "test.c:17") of the
+// definition of each function with debug information, keyed by function name.
+// Function names defined in multiple source locations (e.g. static functions of
+// distinct source files) are omitted, as their definition is ambiguous.
+func funcLocations(fns []Func) map[string]string {
+	locs := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	for _, fn := range fns {
+		if fn.NonDebug || fn.Line == 0 {
+			// Source location unknown.
+			continue
+		}
+		name := funcNameFromSig(fn.Sig)
+		loc := fmt.Sprintf("%s:%d", fn.File, fn.Line)
+		if prev, ok := locs[name]; ok && prev != loc {
+			ambiguous[name] = true
+		}
+		locs[name] = loc
+	}
+	for name := range ambiguous {
+		delete(locs, name)
+	}
+	return locs
+}
+
+// writeClusters writes node declarations of the given function names with the
+// specified node attributes to w, grouped into clusters based on the source
+// file of each function. Functions with unknown source file are grouped into a
+// catch-all cluster. Each line is prefixed by indent, and clusters are named
+// using the specified cluster name prefix.
+//
+// The source file of each function is primarily located using the stack frames
+// of the call graph, as functions of distinct source files may share the same
+// function name (e.g. static functions); falling back to the source file of the
+// debug information about functions.
+//
+// Example output:
+//
+//    subgraph cluster_0 {
+//       label="test.c"
+//       "main"
+//       "foo"
+//    }
+func writeClusters(w *bufio.Writer, names []string, frames map[string]StackFrame, fns []Func, ids *nodeIDs, nodeAttrs map[string]dotAttrs, indent, clusterPrefix string) {
+	// Source file of each function, keyed by function name.
+	funcFile := make(map[string]string)
+	for name, frame := range frames {
+		if len(frame.SrcFile) > 0 {
+			funcFile[name] = frame.SrcFile
+		}
+	}
+	for _, fn := range fns {
+		if fn.NonDebug {
+			// Source file unknown.
+			continue
+		}
+		name := funcNameFromSig(fn.Sig)
+		if _, ok := funcFile[name]; !ok {
+			funcFile[name] = fn.File
+		}
+	}
+	// Function names of each source file, keyed by source file.
+	files := make(map[string][]string)
+	// Function names with unknown source file.
+	var unknown []string
+	for _, name := range names {
+		file, ok := funcFile[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		files[file] = append(files[file], name)
+	}
+	var fileNames []string
+	for file := range files {
+		fileNames = append(fileNames, file)
+	}
+	sort.Strings(fileNames)
+	// writeCluster writes a cluster with the given label and function names.
+	writeCluster := func(i int, label string, names []string) {
+		fmt.Fprintf(w, "%ssubgraph %s%d {\n", indent, clusterPrefix, i)
+		fmt.Fprintf(w, "%s\tlabel=%q\n", indent, label)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%s%s\n", indent, dotNode(ids, name), nodeAttrs[name])
+		}
+		fmt.Fprintf(w, "%s}\n", indent)
+	}
+	for i, file := range fileNames {
+		writeCluster(i, file, files[file])
+	}
+	if len(unknown) > 0 {
+		writeCluster(len(fileNames), "unknown", unknown)
+	}
+}
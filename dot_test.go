@@ -0,0 +1,134 @@
+package callgraph
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteDOTClusterSameBaseName(t *testing.T) {
+	main := StackFrame{StackFrameNum: 1, FuncName: "main", SrcFile: "main.c", LineNum: 7}
+	edges := []Edge{
+		{Src: main, Dst: StackFrame{FuncName: "parse", SrcFile: "src/a/util.c", LineNum: 3}},
+		{Src: main, Dst: StackFrame{FuncName: "format", SrcFile: "src/b/util.c", LineNum: 5}},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteDOT(buf, edges, &DOTOptions{Cluster: true}); err != nil {
+		t.Fatalf("unable to write DOT; %+v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"\t\tlabel=\"src/a/util.c\"\n\t\t\"parse\" [",
+		"\t\tlabel=\"src/b/util.c\"\n\t\t\"format\" [",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing cluster %q in DOT output:\n%s", want, got)
+		}
+	}
+}
+
+func TestWriteDOTInlined(t *testing.T) {
+	edges := []Edge{
+		{Src: StackFrame{FuncName: "foo"}, Dst: StackFrame{FuncName: "bar"}},
+		{Src: StackFrame{FuncName: "bar"}, Dst: StackFrame{FuncName: "baz", Inlined: true}},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteDOT(buf, edges, nil); err != nil {
+		t.Fatalf("unable to write DOT; %+v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{"\t\"foo\" -> \"bar\"\n", "\t\"bar\" -> \"baz\" [style=\"dashed\"]\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing edge %q in DOT output:\n%s", want, got)
+		}
+	}
+}
+
+func TestWriteDOTNodeStyles(t *testing.T) {
+	edges := []Edge{
+		{Dst: StackFrame{FuncName: "main"}},
+		{Src: StackFrame{FuncName: "main"}, Dst: StackFrame{FuncName: "foo"}},
+	}
+	opts := &DOTOptions{
+		Main:            "main",
+		Focus:           "foo",
+		Heat:            map[string]float64{"main": 1, "foo": 2},
+		HighlightLeaves: true,
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteDOT(buf, edges, opts); err != nil {
+		t.Fatalf("unable to write DOT; %+v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`"main" [tooltip="in=0 out=1", style="filled,bold", fillcolor="#FFFFFF", peripheries="2"]`,
+		`"foo" [tooltip="in=1 out=0", style="filled,bold", fillcolor="#FF0000", shape="box", penwidth="2"]`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing node %q in DOT output:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "style="); n != 2 {
+		t.Errorf("number of style attributes mismatch; expected 2, got %d:\n%s", n, got)
+	}
+}
+
+func TestWriteDOTHighlightCycles(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	edges := []Edge{
+		{Dst: main},
+		{Src: main, Dst: foo},
+		{Src: foo, Dst: foo},
+		{Src: foo, Dst: foo},
+		{Src: main, Dst: bar},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteDOT(buf, edges, &DOTOptions{HighlightCycles: true}); err != nil {
+		t.Fatalf("unable to write DOT; %+v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`"foo" [tooltip="in=2 out=1", color="red"]`,
+		"\t\"foo\" -> \"foo\" [label=\"×2\"]\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in DOT output:\n%s", want, got)
+		}
+	}
+	// Only nodes of cycles are highlighted.
+	if n := strings.Count(got, `color="red"`); n != 1 {
+		t.Errorf("number of highlighted nodes mismatch; expected 1, got %d:\n%s", n, got)
+	}
+}
+
+func TestWriteDOTDepths(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	baz := StackFrame{FuncName: "baz"}
+	edges := []Edge{
+		{Dst: main},
+		{Src: main, Dst: foo},
+		{Src: main, Dst: bar},
+		{Src: foo, Dst: baz},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteDOT(buf, edges, &DOTOptions{Depths: true}); err != nil {
+		t.Fatalf("unable to write DOT; %+v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`label="main\ndepth 0"`,
+		`label="foo\ndepth 1"`,
+		`label="baz\ndepth 2"`,
+		"\t{rank=same; \"main\";}\n",
+		"\t{rank=same; \"foo\"; \"bar\";}\n",
+		"\t{rank=same; \"baz\";}\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in DOT output:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package callgraph
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"debug/macho"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// DWARFFuncs retrieves debug information about functions of the given ELF or
+// Mach-O binary executable by parsing its DWARF debug information directly,
+// without invoking a debugger. Functions are located based on the subprogram
+// debugging information entries (DIEs) of each compilation unit.
+func DWARFFuncs(binPath string) ([]Func, error) {
+	d, err := openDWARF(binPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fns, err := dwarfFuncs(d)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(fns) == 0 {
+		return nil, errors.Errorf("unable to locate any debug information of functions in %q", binPath)
+	}
+	return fns, nil
+}
+
+// openDWARF returns the DWARF debug information of the given binary
+// executable, based on its binary format (see DetectFormat).
+func openDWARF(binPath string) (*dwarf.Data, error) {
+	format, err := DetectFormat(binPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var d *dwarf.Data
+	switch format {
+	case FormatELF:
+		f, err := elf.Open(binPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer f.Close()
+		d, err = f.DWARF()
+	case FormatMachO:
+		f, err := macho.Open(binPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer f.Close()
+		d, err = f.DWARF()
+	case FormatFatMachO:
+		// Use the first architecture of universal binaries.
+		ff, err := macho.OpenFat(binPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer ff.Close()
+		if len(ff.Arches) == 0 {
+			return nil, errors.Errorf("unable to locate any architecture of universal binary %q", binPath)
+		}
+		d, err = ff.Arches[0].DWARF()
+	default:
+		return nil, errors.Errorf("unsupported binary format of %q; expected ELF or Mach-O", binPath)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse DWARF debug information of %q", binPath)
+	}
+	return d, nil
+}
+
+// dwarfFuncs returns the functions of the given DWARF debug information, sorted
+// by source file and line number.
+func dwarfFuncs(d *dwarf.Data) ([]Func, error) {
+	var fns []Func
+	// Tracks functions already seen, keyed by source location.
+	type key struct {
+		file string
+		line int
+	}
+	seen := make(map[key]bool)
+	// Source files of current compilation unit.
+	var files []*dwarf.LineFile
+	r := d.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if entry == nil {
+			break
+		}
+		switch entry.Tag {
+		case dwarf.TagCompileUnit:
+			files = nil
+			lr, err := d.LineReader(entry)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if lr != nil {
+				files = lr.Files()
+			}
+			continue
+		case dwarf.TagSubprogram:
+			// handled below.
+		default:
+			continue
+		}
+		if entry.Val(dwarf.AttrLowpc) == nil {
+			// Skip declarations and abstract instances of inlined functions,
+			// which lack machine code.
+			continue
+		}
+		// Name and source location of concrete out-of-line instances of
+		// functions (e.g. C++ member functions defined outside of their class)
+		// are specified by the referenced declaration DIE.
+		decl := entry
+		for _, attr := range []dwarf.Attr{dwarf.AttrSpecification, dwarf.AttrAbstractOrigin} {
+			off, ok := entry.Val(attr).(dwarf.Offset)
+			if !ok {
+				continue
+			}
+			dr := d.Reader()
+			dr.Seek(off)
+			if e, err := dr.Next(); err == nil && e != nil {
+				decl = e
+			}
+			break
+		}
+		name, _ := decl.Val(dwarf.AttrName).(string)
+		if len(name) == 0 {
+			continue
+		}
+		// Prefer demangled linkage name of C++ functions, as it includes the
+		// namespace and class of the function.
+		sig := name
+		for _, e := range []*dwarf.Entry{entry, decl} {
+			if linkageName, ok := e.Val(dwarf.AttrLinkageName).(string); ok {
+				sig = demangle(linkageName)
+				break
+			}
+		}
+		fileIndex, ok := decl.Val(dwarf.AttrDeclFile).(int64)
+		if !ok || fileIndex < 0 || int(fileIndex) >= len(files) || files[fileIndex] == nil {
+			continue
+		}
+		line, _ := decl.Val(dwarf.AttrDeclLine).(int64)
+		fn := Func{
+			Name: funcNameFromSig(sig),
+			File: files[fileIndex].Name,
+			Line: int(line),
+			Sig:  sig,
+		}
+		k := key{file: fn.File, line: fn.Line}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		fns = append(fns, fn)
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		a := fns[i]
+		b := fns[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return fns, nil
+}
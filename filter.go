@@ -0,0 +1,141 @@
+package callgraph
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FilterFuncs filters the given functions based on the include and exclude
+// regular expressions, matched against the signature and source file of each
+// function. If include is non-nil, only functions matching include are kept.
+// Excludes are applied after includes; functions matching exclude are dropped.
+func FilterFuncs(fns []Func, include, exclude *regexp.Regexp) []Func {
+	var filtered []Func
+	for _, fn := range fns {
+		if include != nil && !matchFunc(include, fn) {
+			continue
+		}
+		if exclude != nil && matchFunc(exclude, fn) {
+			continue
+		}
+		filtered = append(filtered, fn)
+	}
+	return filtered
+}
+
+// matchFunc reports whether the given regular expression matches the signature
+// or source file of the function.
+func matchFunc(re *regexp.Regexp, fn Func) bool {
+	return re.MatchString(fn.Sig) || re.MatchString(fn.File)
+}
+
+// FilterFuncsByFile returns the functions defined in source files matching any
+// of the given glob patterns (e.g. "src/net/*.c"), as matched by path.Match
+// against the source file of each function. An error is returned if no
+// function matches any of the glob patterns.
+func FilterFuncsByFile(fns []Func, globs []string) ([]Func, error) {
+	var filtered []Func
+	for _, fn := range fns {
+		for _, glob := range globs {
+			match, err := path.Match(glob, fn.File)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid glob pattern %q", glob)
+			}
+			if match {
+				filtered = append(filtered, fn)
+				break
+			}
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, errors.Errorf("unable to locate any function defined in source files matching %s", strings.Join(globs, ", "))
+	}
+	return filtered, nil
+}
+
+// SelectFuncs returns the functions with the given function names, matched
+// against the function name of each function signature (or the signature in
+// its entirety). An error is returned if any of the named functions could not
+// be located, listing the closest matches.
+func SelectFuncs(fns []Func, names []string) ([]Func, error) {
+	var selected []Func
+	found := make(map[string]bool)
+	for _, fn := range fns {
+		name := funcNameFromSig(fn.Sig)
+		for _, want := range names {
+			if want == name || want == fn.Sig {
+				selected = append(selected, fn)
+				found[want] = true
+				break
+			}
+		}
+	}
+	for _, want := range names {
+		if found[want] {
+			continue
+		}
+		closest := closestFuncNames(fns, want, 3)
+		if len(closest) == 0 {
+			return nil, errors.Errorf("unable to locate function %q in debug information", want)
+		}
+		return nil, errors.Errorf("unable to locate function %q in debug information; closest matches: %s", want, strings.Join(closest, ", "))
+	}
+	return selected, nil
+}
+
+// closestFuncNames returns the n function names of the given functions closest
+// to name, as measured by edit distance.
+func closestFuncNames(fns []Func, name string, n int) []string {
+	dists := make(map[string]int)
+	var names []string
+	for _, fn := range fns {
+		fnName := funcNameFromSig(fn.Sig)
+		if _, ok := dists[fnName]; ok {
+			continue
+		}
+		dists[fnName] = editDistance(name, fnName)
+		names = append(names, fnName)
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return dists[names[i]] < dists[names[j]]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// minInt returns the smallest of the given integers.
+func minInt(x int, ys ...int) int {
+	for _, y := range ys {
+		if y < x {
+			x = y
+		}
+	}
+	return x
+}
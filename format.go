@@ -0,0 +1,54 @@
+package callgraph
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Format is a binary executable format.
+type Format int
+
+// Binary executable formats.
+const (
+	// Unknown binary format.
+	FormatUnknown Format = iota
+	// Executable and Linkable Format (e.g. Linux).
+	FormatELF
+	// Mach-O (e.g. macOS).
+	FormatMachO
+	// Universal (i.e. fat) Mach-O binary of multiple architectures.
+	FormatFatMachO
+)
+
+// DetectFormat detects the binary format of the given binary executable based
+// on its magic bytes.
+func DetectFormat(binPath string) (Format, error) {
+	f, err := os.Open(binPath)
+	if err != nil {
+		return FormatUnknown, errors.WithStack(err)
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return FormatUnknown, nil
+		}
+		return FormatUnknown, errors.WithStack(err)
+	}
+	switch {
+	case bytes.Equal(magic, []byte("\x7fELF")):
+		return FormatELF, nil
+	// 32- and 64-bit Mach-O, in big- and little-endian byte order.
+	case bytes.Equal(magic, []byte{0xFE, 0xED, 0xFA, 0xCE}),
+		bytes.Equal(magic, []byte{0xCE, 0xFA, 0xED, 0xFE}),
+		bytes.Equal(magic, []byte{0xFE, 0xED, 0xFA, 0xCF}),
+		bytes.Equal(magic, []byte{0xCF, 0xFA, 0xED, 0xFE}):
+		return FormatMachO, nil
+	case bytes.Equal(magic, []byte{0xCA, 0xFE, 0xBA, 0xBE}):
+		return FormatFatMachO, nil
+	}
+	return FormatUnknown, nil
+}
@@ -0,0 +1,1297 @@
+package callgraph
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kr/pretty"
+	"github.com/pkg/errors"
+)
+
+// GDB is a Debugger which traces call graphs of binary executables using GDB.
+type GDB struct {
+	// Path to GDB executable.
+	Path string
+	// Regular expression of functions to trace, with breakpoints added using
+	// the GDB rbreak command rather than one breakpoint per function; empty to
+	// add breakpoints per function. The traced functions are the breakpoints
+	// added by rbreak, in order (see RBreakFuncs).
+	RBreak string
+	// Register breakpoints using a GDB Python script, which records the
+	// backtrace of each breakpoint hit as a JSON encoded line, rather than one
+	// "commands" block per breakpoint.
+	Python bool
+	// Function reached once the traced program has loaded its shared libraries
+	// at runtime (e.g. plugins loaded using dlopen); empty to retrieve functions
+	// at startup. If set, functions are retrieved once the traced program
+	// reaches the function, and breakpoints are added as pending breakpoints,
+	// so that functions of shared libraries loaded at runtime are traced.
+	BreakAfter string
+	// Tracing options.
+	Options
+}
+
+// NewGDB returns a new GDB tracer with default settings.
+func NewGDB() *GDB {
+	return &GDB{
+		Path:    "gdb",
+		Options: DefaultOptions(),
+	}
+}
+
+// Trace traces the call graph of the specified functions in the given binary
+// and returns the edges of the call graph. The output of GDB is parsed while
+// tracing, so that the output of long-running traces is never kept in memory.
+//
+// Functions whose breakpoints GDB fails to insert (e.g. located in read-only
+// sections) are skipped with a warning, and the trace is restarted with the
+// remaining functions.
+func (g *GDB) Trace(binPath string, fns []Func) ([]Edge, error) {
+	if len(g.Core) > 0 {
+		return g.traceCore(binPath)
+	}
+	if g.Returns && !g.Python {
+		return nil, errors.Errorf("recording of returns requires GDB Python mode")
+	}
+	if len(g.RBreak) > 0 {
+		return g.traceRBreak(binPath, fns)
+	}
+	// Breakpoint numbers are assigned to functions with debug information in
+	// order (see traceScript).
+	fns = DebugFuncs(fns)
+	for {
+		edges, failed, err := g.trace(binPath, g.traceScript(fns, nil), fns)
+		if len(failed) == 0 {
+			return edges, err
+		}
+		var remaining []Func
+		for i, fn := range fns {
+			breakNr := i + 1
+			reason, ok := failed[breakNr]
+			if !ok {
+				remaining = append(remaining, fn)
+				continue
+			}
+			log.Printf("warning: unable to insert breakpoint %d of %q at %s:%d (%s); skipping function", breakNr, fn.Sig, fn.File, fn.Line, reason)
+		}
+		if len(remaining) == len(fns) {
+			// Failed breakpoints not correlated to functions.
+			return edges, err
+		}
+		if len(remaining) == 0 {
+			return nil, errors.Errorf("unable to insert breakpoints of any function in %q", binPath)
+		}
+		fns = remaining
+	}
+}
+
+// trace traces the call graph of the specified functions in the given binary
+// using the given GDB command script, and returns the edges of the call graph,
+// and the reasons of failed breakpoint insertions keyed by breakpoint number.
+func (g *GDB) trace(binPath, script string, fns []Func) ([]Edge, map[int]string, error) {
+	pr, pw := io.Pipe()
+	edgec, errc := g.parseEdgesReader(pr, fns)
+	runErrc := make(chan error, 1)
+	// Standard error output of GDB, which reports failed breakpoint insertions.
+	stderr := &bytes.Buffer{}
+	go func() {
+		err := g.runTo(binPath, script, pw, stderr)
+		pw.Close()
+		runErrc <- err
+	}()
+	var edges []Edge
+	for edge := range edgec {
+		if g.OnEdge != nil {
+			g.OnEdge(edge)
+		}
+		edges = append(edges, edge)
+	}
+	if err := <-errc; err != nil {
+		// Discard remaining output, so that GDB does not block on writes to the
+		// output pipe.
+		io.Copy(ioutil.Discard, pr)
+		if runErr := <-runErrc; runErr != nil {
+			// GDB failed to run or exited early (e.g. missing executable,
+			// timeout or crash), truncating its output; report the cause
+			// rather than the resulting parse error.
+			return nil, parseFailedBreakpoints(stderr.String()), errors.WithStack(runErr)
+		}
+		return nil, nil, errors.WithStack(err)
+	}
+	if g.MaxDepth >= 0 {
+		edges = pruneDepth(edges, g.MaxDepth)
+	}
+	err := <-runErrc
+	failed := parseFailedBreakpoints(stderr.String())
+	if err != nil {
+		if IsPartial(err) {
+			// Edges of partial output of hung or failed trace.
+			return edges, failed, err
+		}
+		return nil, failed, errors.WithStack(err)
+	}
+	return edges, failed, nil
+}
+
+// parseFailedBreakpoints parses the failed breakpoint insertions reported in the
+// given GDB output, and returns the reason of each failure keyed by breakpoint
+// number.
+//
+// Example GDB output:
+//
+//    Warning:
+//    Cannot insert breakpoint 3.
+//    Cannot access memory at address 0x1139
+//
+//    Command aborted.
+func parseFailedBreakpoints(output string) map[int]string {
+	const prefix = "Cannot insert breakpoint "
+	failed := make(map[int]string)
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		breakNr, err := strconv.Atoi(strings.TrimSuffix(line[len(prefix):], "."))
+		if err != nil {
+			continue
+		}
+		reason := "unknown reason"
+		if i+1 < len(lines) && len(strings.TrimSpace(lines[i+1])) > 0 {
+			reason = strings.TrimSpace(lines[i+1])
+		}
+		failed[breakNr] = reason
+	}
+	return failed
+}
+
+// ParseTrace parses the call graph edges of the given pre-captured GDB trace
+// output, as produced by the GDB command script of Trace.
+func (g *GDB) ParseTrace(output string, fns []Func) ([]Edge, error) {
+	return g.parseEdges(output, fns)
+}
+
+// ParseTraceReader parses the call graph edges of the pre-captured GDB trace
+// output read from r, as produced by the GDB command script of Trace. The
+// output is parsed line by line, so that huge GDB logs are never kept in
+// memory.
+func (g *GDB) ParseTraceReader(r io.Reader, fns []Func) ([]Edge, error) {
+	edgec, errc := g.parseEdgesReader(r, fns)
+	var edges []Edge
+	for edge := range edgec {
+		if g.OnEdge != nil {
+			g.OnEdge(edge)
+		}
+		edges = append(edges, edge)
+	}
+	if err := <-errc; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if g.MaxDepth >= 0 {
+		edges = pruneDepth(edges, g.MaxDepth)
+	}
+	return edges, nil
+}
+
+// FuncsScript returns the GDB command script used to retrieve debug information
+// about functions (see Funcs).
+func (g *GDB) FuncsScript() string {
+	if len(g.BreakAfter) > 0 {
+		return g.funcsAfterScript()
+	}
+	return gdbGetFuncs
+}
+
+// TraceScript returns the GDB command script used to trace the call graph of
+// the specified functions (see Trace).
+func (g *GDB) TraceScript(fns []Func) string {
+	if len(g.Core) > 0 {
+		return gdbCoreBacktrace
+	}
+	return g.traceScript(fns, nil)
+}
+
+// traceScript returns the GDB command script used to trace the call graph of
+// the specified functions. The breakpoints added by rbreak (see g.RBreak) with
+// the given breakpoint numbers are deleted.
+func (g *GDB) traceScript(fns []Func, deleted map[int]bool) string {
+	if len(g.RBreak) == 0 {
+		// Breakpoints may only be added to functions with debug information.
+		fns = DebugFuncs(fns)
+	}
+	input := &bytes.Buffer{}
+	fmt.Fprintf(input, "set width 0\n")
+	fmt.Fprintf(input, "set height 0\n")
+	fmt.Fprintf(input, "set verbose off\n")
+	// Print the values of all arguments in stack frames, including non-scalar
+	// arguments, which are otherwise elided as "...". This ensures that the
+	// arguments of the caller (#1) are captured in full.
+	fmt.Fprintf(input, "set print frame-arguments all\n")
+	if len(g.BreakAfter) > 0 {
+		// Add breakpoints of functions in shared libraries not yet loaded as
+		// pending breakpoints, which are resolved once the shared library is
+		// loaded.
+		fmt.Fprintf(input, "set breakpoint pending on\n")
+	}
+	// Number of stack frames to include in backtrace. To determine the call
+	// depth of a callee, the backtrace window is widened to at least MaxDepth+1
+	// frames.
+	n := g.backtraceWindow()
+	if g.MaxDepth >= 0 && g.MaxDepth+1 > n {
+		n = g.MaxDepth + 1
+	}
+	switch {
+	case len(g.RBreak) > 0:
+		g.rbreakScript(input, fns, deleted, n)
+	case g.Python:
+		input.WriteString(g.pythonScript(fns, n))
+	default:
+		g.commandsScript(input, fns, n)
+	}
+	g.inferiorScript(input)
+	fmt.Fprintf(input, "%s\n", g.runCommand())
+	return input.String()
+}
+
+// inferiorScript writes the GDB commands setting up the environment and
+// working directory of the traced program to w.
+func (g *GDB) inferiorScript(w *bytes.Buffer) {
+	if g.PID != 0 {
+		// The environment of a running process is already set up.
+		return
+	}
+	// Restore the locale of the traced program, which is otherwise inherited
+	// from GDB (see gdbEnv).
+	if locale, ok := os.LookupEnv(localeEnv); ok {
+		fmt.Fprintf(w, "set environment %s %s\n", localeEnv, locale)
+	} else {
+		fmt.Fprintf(w, "unset environment %s\n", localeEnv)
+	}
+	// Example:
+	//
+	//    set environment FEATURE_X 1
+	for _, env := range g.Env {
+		key, val := splitEnv(env)
+		fmt.Fprintf(w, "set environment %s %s\n", key, val)
+	}
+	if len(g.WorkingDir) > 0 {
+		// Set working directory of the traced program only, so that source
+		// file paths are still resolved by GDB as before.
+		fmt.Fprintf(w, "set cwd %s\n", g.WorkingDir)
+	}
+}
+
+// commandsScript writes the GDB commands adding breakpoints of the given
+// functions to w, with one "commands" block per breakpoint recording a
+// backtrace of n stack frames.
+func (g *GDB) commandsScript(w *bytes.Buffer, fns []Func, n int) {
+	// Add breakpoints. The source file path is used as listed by GDB (e.g.
+	// "src/a/util.c" rather than "util.c"), so that breakpoints are not
+	// ambiguous between source files sharing the same base name.
+	for _, fn := range fns {
+		if cond, ok := g.Conditions[funcNameFromSig(fn.Sig)]; ok {
+			// Example:
+			//
+			//    break render.c:42 if layer == 2
+			fmt.Fprintf(w, "break %s:%d if %s\n", fn.File, fn.Line, cond)
+			continue
+		}
+		fmt.Fprintf(w, "break %s:%d\n", fn.File, fn.Line)
+	}
+	// Hook backtrace command for each breakpoint.
+	var breakNrs []int
+	for i := range fns {
+		breakNr := i + 1
+		fmt.Fprintf(w, "commands %d\n", breakNr)
+		g.hookScript(w, n)
+		fmt.Fprintf(w, "end\n")
+		breakNrs = append(breakNrs, breakNr)
+	}
+	g.hitLimitScript(w, breakNrs)
+}
+
+// hitLimitScript writes the GDB command limiting the number of hits of the
+// breakpoints with the given sorted breakpoint numbers to g.HitsPerFunc (if
+// set) to w. Each breakpoint is disabled once its count of hits is reached.
+//
+// Example:
+//
+//    enable count 100 1-4
+func (g *GDB) hitLimitScript(w *bytes.Buffer, breakNrs []int) {
+	if g.HitsPerFunc <= 0 || len(breakNrs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "enable count %d %s\n", g.HitsPerFunc, breakpointRanges(breakNrs))
+}
+
+// hookScript writes the GDB commands of breakpoint hooks to w, recording a
+// backtrace of n stack frames before continuing execution.
+func (g *GDB) hookScript(w *bytes.Buffer, n int) {
+	//fmt.Fprintf(w, "info args\n")
+	fmt.Fprintf(w, "backtrace %d\n", n)
+	if g.Threads {
+		// Example output:
+		//
+		//    [Current thread is 2 (Thread 0x7ffff7a4e640 (LWP 4242))]
+		fmt.Fprintf(w, "thread\n")
+	}
+	if g.ResolveSymbols {
+		// Look up symbol of call site address in caller stack frame (#1).
+		// Note, the remaining commands are skipped if the callee is the
+		// outermost stack frame.
+		fmt.Fprintf(w, "up-silently\n")
+		fmt.Fprintf(w, "info symbol $pc\n")
+		fmt.Fprintf(w, "down-silently\n")
+	}
+	fmt.Fprintf(w, "continue\n")
+}
+
+// runCommand returns the GDB run command used to launch the traced program,
+// with command line arguments and standard input redirection. The inferior's
+// standard input is redirected from a file, so that it is kept separate from
+// the GDB command script supplied to the standard input of GDB.
+//
+// When attached to a running process, execution is instead continued until the
+// process exits or is interrupted at the end of the live tracing window, after
+// which GDB detaches from the process.
+//
+// Example:
+//
+//    run '--headless' 'level1.map' < 'input.txt'
+func (g *GDB) runCommand() string {
+	if g.PID != 0 {
+		return "continue\ndetach"
+	}
+	cmd := "run"
+	if len(g.Args) > 0 {
+		cmd += " " + shellQuote(g.Args)
+	}
+	if len(g.Stdin) > 0 {
+		cmd += " < " + shellQuote([]string{g.Stdin})
+	}
+	return cmd
+}
+
+// run runs GDB on the given binary executable (or attached to the running
+// process of g.PID), feeding it the specified command script, and returns the
+// output of GDB. If GDB is killed for exceeding the tracing timeout, or exits
+// with a non-zero exit status, the output captured so far is returned alongside
+// ErrTimeout or ErrExitStatus respectively.
+func (g *GDB) run(binPath, script string) (string, error) {
+	output := &bytes.Buffer{}
+	if err := g.runTo(binPath, script, output, nil); err != nil {
+		if IsPartial(err) {
+			return output.String(), err
+		}
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// runTo runs GDB on the given binary executable (or attached to the running
+// process of g.PID), feeding it the specified command script, and writes the
+// output of GDB to w, and its standard error output to errw and g.Stderr if
+// non-nil. ErrTimeout is returned if GDB exceeds the tracing timeout.
+func (g *GDB) runTo(binPath, script string, w, errw io.Writer) error {
+	input := strings.NewReader(script)
+	errbuf := &bytes.Buffer{}
+	ctx, cancel := g.newContext()
+	defer cancel()
+	args := []string{"-q"}
+	if len(binPath) > 0 {
+		args = append(args, binPath)
+	}
+	// Duration after which to interrupt the traced program.
+	var interrupt time.Duration
+	if len(g.Core) > 0 {
+		// Load core dump.
+		args = append(args, g.Core)
+	}
+	if g.PID != 0 {
+		// Attach to running process.
+		args = append(args, "-p", strconv.Itoa(g.PID))
+		interrupt = g.Duration
+	}
+	cmd := exec.CommandContext(ctx, g.Path, args...)
+	cmd.Env = gdbEnv()
+	cmd.Stdin = input
+	cmd.Stdout = w
+	stderr := []io.Writer{errbuf}
+	if errw != nil {
+		stderr = append(stderr, errw)
+	}
+	if g.Stderr != nil {
+		stderr = append(stderr, g.Stderr)
+	}
+	cmd.Stderr = io.MultiWriter(stderr...)
+	if err := runProcessGroup(ctx, cmd, interrupt); err != nil {
+		if errors.Cause(err) == ErrTimeout {
+			return errors.Wrapf(err, "GDB killed after %v", g.Timeout)
+		}
+		return errors.Wrapf(err, "GDB error: %v", errbuf)
+	}
+	return nil
+}
+
+// localeEnv is the environment variable overriding the locale of GDB.
+const localeEnv = "LC_ALL"
+
+// gdbEnv returns the environment of GDB, forcing the C locale so that GDB
+// output is not localized (e.g. "Point d'arrêt" instead of "Breakpoint").
+func gdbEnv() []string {
+	var env []string
+	for _, e := range os.Environ() {
+		if key, _ := splitEnv(e); key == localeEnv {
+			continue
+		}
+		env = append(env, e)
+	}
+	return append(env, localeEnv+"=C")
+}
+
+// parseEdges parses call graph edges in the given GDB output.
+//
+// Example GDB output:
+//
+//    Breakpoint 1, main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+//    11      foo(23);
+//    #0  main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+//
+//    Breakpoint 2, foo (n=23) at test.c:19
+//    19      bar(n);
+//    #0  foo (n=23) at test.c:19
+//    #1  0x0000555555555152 in main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+//
+//    Breakpoint 3, bar (n=23) at test.c:25
+//    25      baz(n);
+//    #0  bar (n=23) at test.c:25
+//    #1  0x0000555555555171 in foo (n=23) at test.c:19
+//
+//    Breakpoint 4, baz (n=23) at test.c:31
+//    31      return;
+//    #0  baz (n=23) at test.c:31
+//    #1  0x0000555555555189 in bar (n=23) at test.c:25
+//
+// An edge is recorded for each pair of consecutive stack frames within the
+// backtrace window (see Options.Backtrace), thus recovering the complete call
+// chain of each breakpoint even when intermediate functions lack their own
+// breakpoints. Only edges up to g.MaxDepth levels deep from the roots are
+// recorded.
+func (g *GDB) parseEdges(s string, fns []Func) ([]Edge, error) {
+	return g.ParseTraceReader(strings.NewReader(s), fns)
+}
+
+// maxLineLen specifies the maximum line length of GDB output, which may contain
+// long argument values of stack frames.
+const maxLineLen = 16 * 1024 * 1024
+
+// parseEdgesReader parses call graph edges in the GDB output read from r, line
+// by line, emitting the edges of each breakpoint as soon as the complete output
+// of the breakpoint has been read. At most one error is sent on the error
+// channel, after the edge channel has been closed.
+//
+// Edges are not pruned based on call depth, as the call depth of callees is
+// only known after all edges have been parsed (see pruneDepth).
+func (g *GDB) parseEdgesReader(r io.Reader, fns []Func) (<-chan Edge, <-chan error) {
+	edgec := make(chan Edge)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(edgec)
+		// Output lines of current breakpoint; nil within preamble output (e.g.
+		// "Reading symbols from ./test").
+		var lines []string
+		// Index of current breakpoint hit.
+		index := -1
+		// GDB output reports added breakpoints or breakpoint hits.
+		seenBreakpoint := false
+		// Progress of trace.
+		var progress Progress
+		// report reports the progress of the trace, given whether the current
+		// GDB output line reports an added breakpoint or a breakpoint hit.
+		report := func(added bool) {
+			if g.OnProgress == nil {
+				return
+			}
+			if added {
+				progress.Breakpoints++
+			} else {
+				progress.Hits++
+			}
+			g.OnProgress(progress)
+		}
+		// flush emits the edges of the current breakpoint.
+		flush := func() error {
+			if lines == nil {
+				return nil
+			}
+			edges, err := g.parseBreakpoint(lines, index)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			for _, edge := range edges {
+				edgec <- edge
+			}
+			return nil
+		}
+		s := bufio.NewScanner(r)
+		s.Buffer(nil, maxLineLen)
+		for s.Scan() {
+			line := s.Text()
+			if isPythonHit(line) {
+				// Breakpoint hit of GDB Python breakpoint script (see g.Python).
+				if err := flush(); err != nil {
+					errc <- err
+					return
+				}
+				lines = nil
+				index++
+				seenBreakpoint = true
+				report(false)
+				edges, err := g.parsePythonHit(line, index)
+				if err != nil {
+					errc <- errors.WithStack(err)
+					return
+				}
+				for _, edge := range edges {
+					edgec <- edge
+				}
+				continue
+			}
+			if isBreakpointHit(line) {
+				if err := flush(); err != nil {
+					errc <- err
+					return
+				}
+				seenBreakpoint = true
+				if isBreakpointAdded(line) {
+					// Added breakpoint; not a breakpoint hit, thus not counted
+					// by index.
+					report(true)
+					lines = nil
+					continue
+				}
+				index++
+				report(false)
+				lines = []string{line}
+				continue
+			}
+			if lines != nil {
+				lines = append(lines, line)
+			}
+		}
+		if err := s.Err(); err != nil {
+			errc <- errors.WithStack(err)
+			return
+		}
+		if err := flush(); err != nil {
+			errc <- err
+			return
+		}
+		if !seenBreakpoint && len(DebugFuncs(fns)) > 0 {
+			// GDB reports each added breakpoint, even if never hit.
+			errc <- errors.Errorf("unable to locate %q in GDB output; ensure GDB output is not localized (e.g. LC_ALL=C)", breakpointPrefix)
+		}
+	}()
+	return edgec, errc
+}
+
+// parseBreakpoint parses the call graph edges of the given output lines of a
+// breakpoint hit with the specified index.
+func (g *GDB) parseBreakpoint(lines []string, index int) ([]Edge, error) {
+	var sts []StackFrame
+	// Output line preceding the callee stack frame (#0) of each backtrace;
+	// potentially the source code line of the callee.
+	var srcLines []string
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		st, err := g.parseStrackTrace(line)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if st.StackFrameNum == 0 {
+			srcLine := ""
+			if i > 0 {
+				srcLine = lines[i-1]
+			}
+			srcLines = append(srcLines, srcLine)
+		}
+		sts = append(sts, st)
+	}
+	if g.MaxDepth >= 0 {
+		// Example:
+		//
+		//    (More stack frames follow...)
+		for _, line := range lines {
+			if strings.Contains(line, "(More stack frames follow...)") {
+				// Callee is located deeper than g.MaxDepth levels from the root.
+				return nil, nil
+			}
+		}
+	}
+	backtraces := splitBacktraces(sts)
+	if len(backtraces) == 0 {
+		dbg.Printf("unable to determine caller/callee of stack frame %q", strings.Join(lines, "\n"))
+		return nil, nil
+	}
+	if g.ResolveSymbols {
+		g.resolveCaller(backtraces[0], lines)
+	}
+	bpNum, thread := parseBreakpointHit(lines[0])
+	if thread == 0 {
+		thread = parseCurrentThread(lines)
+	}
+	return g.hitEdges(backtraces, srcLines, index, bpNum, thread), nil
+}
+
+// hitEdges returns the call graph edges of the given backtraces of a breakpoint
+// hit with the specified index, breakpoint number and thread number. srcLines
+// specifies the potential source code line of the callee of each backtrace.
+func (g *GDB) hitEdges(backtraces [][]StackFrame, srcLines []string, index, bpNum, thread int) []Edge {
+	var edges []Edge
+	// Multiple backtraces may be present in breakpoint output; pair each
+	// callee (#0) with its callers.
+	for j, frames := range backtraces {
+		if g.MaxDepth >= 0 && len(frames) > g.MaxDepth+1 {
+			// Callee is located deeper than g.MaxDepth levels from the root.
+			continue
+		}
+		// Only stack frames within the backtrace window are of interest; the
+		// remaining stack frames were only used to determine call depth.
+		if len(frames) > g.backtraceWindow() {
+			frames = frames[:g.backtraceWindow()]
+		}
+		for k := range frames {
+			frames[k].Thread = thread
+		}
+		for i, edge := range backtraceEdges(frames) {
+			edge.Index = index
+			edge.Breakpoint = bpNum
+			if i == 0 && isSrcLine(srcLines[j], edge.Dst.LineNum) {
+				// Source code of callee source line.
+				edge.SrcLine = srcLines[j]
+			}
+			if !g.keepEdge(edge) {
+				continue
+			}
+			dbg.Printf("edge: %# v", pretty.Formatter(edge))
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// breakpointPrefix is the prefix of GDB output lines of breakpoint hits, and of
+// breakpoints added by the command script (e.g. "Breakpoint 1 at 0x1149: file
+// test.c, line 19.").
+const breakpointPrefix = "Breakpoint "
+
+// threadHitPrefix is the prefix of breakpoint hit lines of multithreaded
+// programs, preceding the thread number.
+const threadHitPrefix = "Thread "
+
+// isBreakpointHit reports whether the given GDB output line starts the output
+// of a breakpoint hit.
+//
+// Example GDB output lines:
+//
+//    Breakpoint 3, bar (n=23) at test.c:25
+//    Thread 2 "test" hit Breakpoint 3, bar (n=23) at test.c:25
+func isBreakpointHit(line string) bool {
+	if strings.HasPrefix(line, breakpointPrefix) {
+		return true
+	}
+	return strings.HasPrefix(line, threadHitPrefix) && strings.Contains(line, " hit "+breakpointPrefix)
+}
+
+// isBreakpointAdded reports whether the given GDB output line reports an added
+// breakpoint, rather than a breakpoint hit.
+//
+// Example GDB output lines:
+//
+//    Breakpoint 1 at 0x1149: file test.c, line 19.
+//    Breakpoint 5 (plugin.c:12) pending.
+func isBreakpointAdded(line string) bool {
+	if !strings.HasPrefix(line, breakpointPrefix) {
+		return false
+	}
+	fields := strings.Fields(line[len(breakpointPrefix):])
+	return len(fields) >= 2 && (fields[1] == "at" || strings.HasPrefix(fields[1], "("))
+}
+
+// parseBreakpointHit returns the breakpoint number and thread number of the
+// given breakpoint hit line; or 0 if not present.
+//
+// Example GDB output lines:
+//
+//    Breakpoint 3, bar (n=23) at test.c:25
+//    Thread 2 "test" hit Breakpoint 3, bar (n=23) at test.c:25
+func parseBreakpointHit(line string) (bpNum, thread int) {
+	if strings.HasPrefix(line, threadHitPrefix) {
+		if pos := strings.Index(line, " hit Breakpoint "); pos != -1 {
+			fields := strings.Fields(line[len(threadHitPrefix):pos])
+			if len(fields) > 0 {
+				thread, _ = strconv.Atoi(fields[0])
+			}
+			line = line[pos+len(" hit "):]
+		}
+	}
+	if pos := strings.Index(line, ","); pos != -1 {
+		if n, err := strconv.Atoi(strings.TrimPrefix(line[:pos], "Breakpoint ")); err == nil {
+			bpNum = n
+		}
+	}
+	return bpNum, thread
+}
+
+// parseCurrentThread returns the thread number reported by the GDB "thread"
+// command in the given breakpoint output lines; or 0 if not present.
+//
+// Example GDB output line:
+//
+//    [Current thread is 2 (Thread 0x7ffff7a4e640 (LWP 4242))]
+func parseCurrentThread(lines []string) int {
+	const prefix = "[Current thread is "
+	for _, line := range lines {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		fields := strings.Fields(line[len(prefix):])
+		if len(fields) == 0 {
+			continue
+		}
+		if thread, err := strconv.Atoi(strings.TrimSuffix(fields[0], "]")); err == nil {
+			return thread
+		}
+	}
+	return 0
+}
+
+// gdbCoreBacktrace is the GDB command script used to print the backtrace of
+// the crashed thread of a core dump.
+const gdbCoreBacktrace = `
+set width 0
+set height 0
+set verbose off
+set print frame-arguments all
+backtrace
+`
+
+// traceCore records the call chain at crash time of the core dump g.Core of
+// the given binary executable, and returns the edges of the call chain.
+func (g *GDB) traceCore(binPath string) ([]Edge, error) {
+	output, err := g.run(binPath, gdbCoreBacktrace)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	edges, err := g.parseCoreBacktrace(output)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if g.MaxDepth >= 0 {
+		edges = pruneDepth(edges, g.MaxDepth)
+	}
+	return edges, nil
+}
+
+// parseCoreBacktrace parses the call graph edges of the crashed thread in the
+// given GDB output of a core dump, as produced by gdbCoreBacktrace. An edge is
+// recorded for each pair of consecutive stack frames of the backtrace.
+//
+// Example GDB output:
+//
+//    Core was generated by `./test'.
+//    Program terminated with signal SIGSEGV, Segmentation fault.
+//    #0  0x000055555555513d in baz (n=23) at test.c:31
+//    31      *p = n;
+//    #0  0x000055555555513d in baz (n=23) at test.c:31
+//    #1  0x0000555555555189 in bar (n=23) at test.c:25
+//    #2  0x0000555555555171 in foo (n=23) at test.c:19
+//    #3  0x0000555555555152 in main () at test.c:11
+func (g *GDB) parseCoreBacktrace(output string) ([]Edge, error) {
+	lines := strings.Split(output, "\n")
+	var sts []StackFrame
+	// Output line preceding the crashed stack frame (#0) of the backtrace;
+	// potentially the source code line of the crash location.
+	srcLine := ""
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		st, err := g.parseStrackTrace(line)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if st.StackFrameNum == 0 && i > 0 {
+			srcLine = lines[i-1]
+		}
+		sts = append(sts, st)
+	}
+	backtraces := splitBacktraces(sts)
+	if len(backtraces) == 0 {
+		return nil, errors.Errorf("unable to locate backtrace of crashed thread in core dump %q", g.Core)
+	}
+	// The crashed stack frame is printed by GDB when loading the core dump,
+	// followed by the backtrace; use the last (i.e. complete) backtrace.
+	frames := backtraces[len(backtraces)-1]
+	var edges []Edge
+	for i, edge := range backtraceEdges(frames) {
+		if i == 0 && isSrcLine(srcLine, edge.Dst.LineNum) {
+			edge.SrcLine = srcLine
+		}
+		if !g.keepEdge(edge) {
+			continue
+		}
+		dbg.Printf("edge: %# v", pretty.Formatter(edge))
+		edges = append(edges, edge)
+	}
+	return edges, nil
+}
+
+// resolveCaller resolves the function of the caller stack frame (#1) of the
+// given backtrace if address-only, based on the symbol lookup of the call site
+// address in the specified breakpoint output lines (see Options.ResolveSymbols).
+func (g *GDB) resolveCaller(frames []StackFrame, lines []string) {
+	if len(frames) < 2 || !frames[1].Unknown {
+		return
+	}
+	for _, line := range lines {
+		if sym, ok := resolveSymbol(line); ok {
+			frames[1].FuncName = g.demangleName(sym)
+			frames[1].Unknown = false
+			dbg.Printf("resolved caller at 0x%X to %q", frames[1].CallSitePC, frames[1].FuncName)
+			return
+		}
+	}
+}
+
+// resolveSymbol returns the symbol name of the given GDB "info symbol" output
+// line. The boolean return value indicates whether the line contained a
+// resolved symbol.
+//
+// Example "info symbol" output lines:
+//
+//    "Shape::draw + 12 in section .text"
+//    "handler in section .text of /usr/lib/libfoo.so"
+//    "No symbol matches $pc."
+func resolveSymbol(line string) (string, bool) {
+	pos := strings.Index(line, " in section ")
+	if pos == -1 || strings.HasPrefix(line, "#") {
+		return "", false
+	}
+	sym := line[:pos]
+	// Trim offset.
+	if pos := strings.LastIndex(sym, " + "); pos != -1 {
+		sym = sym[:pos]
+	}
+	if len(sym) == 0 {
+		return "", false
+	}
+	return sym, true
+}
+
+// splitBacktraces splits the given stack frames into backtraces, each starting
+// with a callee stack frame (#0). Stack frames preceding the first callee stack
+// frame are skipped. Stack frames of inlined functions are marked as inlined.
+func splitBacktraces(sts []StackFrame) [][]StackFrame {
+	var backtraces [][]StackFrame
+	for _, st := range sts {
+		if st.StackFrameNum == 0 {
+			backtraces = append(backtraces, []StackFrame{st})
+			continue
+		}
+		if len(backtraces) == 0 {
+			dbg.Printf("invalid stack frame number; expected #0, got #%d", st.StackFrameNum)
+			continue
+		}
+		i := len(backtraces) - 1
+		backtraces[i] = append(backtraces[i], st)
+	}
+	for _, frames := range backtraces {
+		markInlined(frames)
+	}
+	return backtraces
+}
+
+// markInlined marks the stack frames of inlined functions in the given
+// backtrace. GDB omits the call site address of caller stack frames whose
+// callee was inlined, as the caller shares the program counter of the inlined
+// callee.
+//
+// Example backtrace of -O2 build, where baz was inlined into bar:
+//
+//    #0  baz (n=23) at test.c:31
+//    #1  bar (n=23) at test.c:25
+//    #2  0x0000555555555171 in foo (n=23) at test.c:19
+func markInlined(frames []StackFrame) {
+	for i := 1; i < len(frames); i++ {
+		if frames[i].CallSitePC == 0 && !frames[i].Unknown {
+			frames[i-1].Inlined = true
+		}
+	}
+}
+
+// isSrcLine reports whether the given breakpoint output line is the source
+// code line with the specified line number. GDB omits the source code line if
+// the source code is not available.
+//
+// Example:
+//
+//    25      baz(n);
+func isSrcLine(line string, lineNum int) bool {
+	lineNumPrefix := strconv.Itoa(lineNum)
+	if !strings.HasPrefix(line, lineNumPrefix) {
+		return false
+	}
+	rest := line[len(lineNumPrefix):]
+	return strings.HasPrefix(rest, " ") || strings.HasPrefix(rest, "\t")
+}
+
+// parseStrackTrace parses the given stack frame line.
+//
+// Example stack frame lines:
+//
+//    "#0  foo (n=23) at test.c:19"
+//    "#1  0x0000555555555171 in foo (n=23) at test.c:19"
+//    "#1  0x56598d16 in CCritSect::CCritSect (this=0x5686a728 <sgMemCrit>) at ./src/storm.h:2079"
+//    "#1  0x5655c988 in _GLOBAL__sub_I_mainmenu.cpp ()"
+//    "#1  0x5655d176 in myDebugBreak () at src/appfat.cpp:87"
+//    "#1  0x0000555555555bd4 in std::map<int, std::string>::insert (this=0x7fffffffe0a0, v=...) at map.h:55"
+//    "#0  apply (f=0x401136 <handler(int)>, n=1) at fp.c:7"
+//    "#0  (anonymous namespace)::helper (x=1) at anon.cc:3"
+//    "#1  0x0000555555555171 in main::{lambda(int)#1}::operator() (__closure=0x7fffffffe0a7, x=1) at lambda.cc:9"
+//    "#1  0x00007ffff7a52000 in ?? ()"
+//
+// Frames of unknown functions ("??") are reported with Unknown set and the
+// function name UnknownFuncName.
+func (g *GDB) parseStrackTrace(line string) (StackFrame, error) {
+	// Stack frame number.
+	//
+	//    "#0  "
+	s := line
+	if !strings.HasPrefix(s, "#") {
+		return StackFrame{}, errors.Errorf("unable to parse stack frame line %q; missing '#' prefix", line)
+	}
+	s = s[len("#"):]
+	end := strings.IndexAny(s, " \t")
+	if end == -1 {
+		return StackFrame{}, errors.Errorf("unable to parse stack frame line %q; missing function name", line)
+	}
+	stackFrameNum, err := strconv.Atoi(s[:end])
+	if err != nil {
+		return StackFrame{}, errors.WithStack(err)
+	}
+	s = strings.TrimLeft(s[end:], " \t")
+	// Optional call site address.
+	//
+	//    "0x0000555555555171 in "
+	var callSitePC uint64
+	if strings.HasPrefix(s, "0x") {
+		if pos := strings.Index(s, " in "); pos != -1 {
+			pc, err := strconv.ParseUint(s[len("0x"):pos], 16, 64)
+			if err != nil {
+				return StackFrame{}, errors.WithStack(err)
+			}
+			callSitePC = pc
+			s = s[pos+len(" in "):]
+		}
+	}
+	// Function name.
+	funcName, s, ok := scanFuncName(s)
+	if !ok {
+		return StackFrame{}, errors.Errorf("unable to parse stack frame line %q; invalid function name", line)
+	}
+	// Function arguments.
+	//
+	//    "(n=23)"
+	argsEnd := scanBalanced(s)
+	if argsEnd == -1 {
+		return StackFrame{}, errors.Errorf("unable to parse stack frame line %q; unbalanced function arguments", line)
+	}
+	st := StackFrame{
+		StackFrameNum: stackFrameNum,
+		FuncName:      g.demangleName(funcName),
+		Args:          s[len("(") : argsEnd-len(")")],
+		CallSitePC:    callSitePC,
+	}
+	// Frame of unknown function (e.g. stripped or JIT code).
+	//
+	//    "#1  0x00007ffff7a52000 in ?? ()"
+	if funcName == "??" {
+		st.FuncName = UnknownFuncName
+		st.Unknown = true
+	}
+	s = s[argsEnd:]
+	// Optional source location.
+	//
+	//    " at test.c:19"
+	const atPrefix = " at "
+	if strings.HasPrefix(s, atPrefix) {
+		loc := s[len(atPrefix):]
+		pos := strings.LastIndex(loc, ":")
+		if pos == -1 {
+			return StackFrame{}, errors.Errorf("unable to parse stack frame line %q; missing line number of source location", line)
+		}
+		lineNum, err := strconv.Atoi(loc[pos+len(":"):])
+		if err != nil {
+			return StackFrame{}, errors.WithStack(err)
+		}
+		st.SrcFile = g.srcPath(loc[:pos])
+		st.LineNum = lineNum
+	}
+	return st, nil
+}
+
+// scanFuncName scans the function name at the start of the given stack frame
+// line suffix (e.g. "foo (n=23) at test.c:19"), and returns the function name
+// and the remaining suffix starting at the argument list (e.g.
+// "(n=23) at test.c:19"). Nested template arguments and parentheses of the
+// function name are skipped, and may thus contain spaces and commas (e.g.
+// "std::map<int, std::string>::insert"). The boolean return value indicates
+// success.
+func scanFuncName(s string) (string, string, bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '<', '(', '[', '{':
+			depth++
+		case '>', ')', ']', '}':
+			depth--
+		case ' ':
+			if depth == 0 && i+1 < len(s) && s[i+1] == '(' {
+				return s[:i], s[i+1:], i > 0
+			}
+		case 'r':
+			// Skip operator symbols (e.g. "operator<<" or "operator()"), which
+			// would otherwise upset the nesting depth.
+			if isOperatorKeyword(s[:i+1]) {
+				i += len(operatorSymbol(s[i+1:]))
+			}
+		}
+	}
+	return "", "", false
+}
+
+// isOperatorKeyword reports whether the given function name prefix ends with
+// the "operator" keyword.
+func isOperatorKeyword(s string) bool {
+	const keyword = "operator"
+	if !strings.HasSuffix(s, keyword) {
+		return false
+	}
+	prefix := s[:len(s)-len(keyword)]
+	if len(prefix) == 0 {
+		return true
+	}
+	// Keyword must not be the suffix of another identifier (e.g. "myoperator").
+	return !isIdentChar(prefix[len(prefix)-1])
+}
+
+// scanBalanced returns the end position of the balanced parenthesized
+// expression at the start of s, or -1 if s does not start with a balanced
+// parenthesized expression. Quoted strings and characters are skipped.
+func scanBalanced(s string) int {
+	if !strings.HasPrefix(s, "(") {
+		return -1
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '\'':
+			// Skip quoted string or character.
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// GDB command to retrieve debug information of function signatures.
+//
+// Example GDB output:
+//    All defined functions:
+//
+//    File test.c:
+//    9:    int main(int, char **);
+//    23:   static void bar(int);
+//    29:   static void baz(int);
+//    17:   static void foo(int);
+//
+//    Non-debugging symbols:
+//    0x0000000000001000  _init
+//    0x0000000000001030  exit@plt
+//    0x0000000000001040  _start
+//    0x0000000000001070  deregister_tm_clones
+//    0x00000000000010a0  register_tm_clones
+//    0x00000000000010e0  __do_global_dtors_aux
+//    0x0000000000001130  frame_dummy
+//    0x00000000000011a0  __libc_csu_init
+//    0x0000000000001210  __libc_csu_fini
+//    0x0000000000001218  _fini
+const gdbGetFuncs = `
+set width 0
+set height 0
+set verbose off
+info functions
+`
+
+// Funcs retrieves debug information about functions of the given binary
+// executable.
+//
+// If g.BreakAfter is set, functions are retrieved once the traced program
+// reaches the function, so that functions of shared libraries loaded at
+// runtime are included.
+func (g *GDB) Funcs(binPath string) ([]Func, error) {
+	if len(g.BreakAfter) > 0 {
+		return g.funcsAfter(binPath)
+	}
+	output, err := g.run(binPath, gdbGetFuncs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fns, err := g.ParseFuncs(output)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fns, nil
+}
+
+// ParseFuncs parses debug information about functions of the given
+// pre-captured GDB output, as produced by the GDB command script of Funcs (see
+// gdbGetFuncs).
+func (g *GDB) ParseFuncs(output string) ([]Func, error) {
+	return g.parseFuncs(output)
+}
+
+// parseFuncs parses debug information about functions of the given GDB output.
+//
+// Example GDB output:
+//    All defined functions:
+//
+//    File test.c:
+//    9:    int main(int, char **);
+//    23:   static void bar(int);
+//    29:   static void baz(int);
+//    17:   static void foo(int);
+//
+//    Non-debugging symbols:
+//    0x0000000000001000  _init
+//    0x0000000000001030  exit@plt
+//    0x0000000000001040  _start
+//    0x0000000000001070  deregister_tm_clones
+//    0x00000000000010a0  register_tm_clones
+//    0x00000000000010e0  __do_global_dtors_aux
+//    0x0000000000001130  frame_dummy
+//    0x00000000000011a0  __libc_csu_init
+//    0x0000000000001210  __libc_csu_fini
+//    0x0000000000001218  _fini
+func (g *GDB) parseFuncs(s string) ([]Func, error) {
+	const startPrefix = "All defined functions:"
+	start := strings.Index(s, startPrefix)
+	if start == -1 {
+		return nil, errors.Errorf("unable to find start position of defined functions; expected %q, got %q", startPrefix, s)
+	}
+	s = s[start:]
+	// Parse file functions.
+	lines := strings.Split(s, "\n")
+	// Current source code file name.
+	srcFile := ""
+	// Parsing non-debugging symbols section.
+	inNonDebug := false
+	var fns []Func
+	var nonDebugFns []Func
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// File test.c:
+		if strings.HasPrefix(line, "File ") && strings.HasSuffix(line, ":") {
+			srcFile = line[len("File ") : len(line)-len(":")]
+			continue
+		}
+		// Non-debugging symbols:
+		if line == "Non-debugging symbols:" {
+			inNonDebug = true
+			continue
+		}
+		if len(line) == 0 {
+			srcFile = ""
+			inNonDebug = false
+			continue
+		}
+		if inNonDebug {
+			// 0x0000000000001000  _init
+			fields := strings.Fields(line)
+			if len(fields) == 2 && strings.HasPrefix(fields[0], "0x") {
+				sym := g.demangleName(fields[1])
+				fn := Func{
+					Name:     sym,
+					Sig:      sym,
+					NonDebug: true,
+				}
+				nonDebugFns = append(nonDebugFns, fn)
+			}
+			continue
+		}
+		if len(srcFile) == 0 {
+			continue
+		}
+		// Split on the first colon only, as C++ signatures contain colons.
+		//
+		//    9:	int main(int, char **);
+		//    14:	int ns::Foo::get(int);
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			rawLine := strings.TrimSpace(parts[0])
+			sig := g.demangleName(strings.TrimSpace(parts[1]))
+			line, err := strconv.Atoi(rawLine)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			fn := Func{
+				Name: funcNameFromSig(sig),
+				File: g.srcPath(srcFile),
+				Line: line,
+				Sig:  sig,
+			}
+			fns = append(fns, fn)
+		}
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		a := fns[i]
+		b := fns[j]
+		switch {
+		case a.File < b.File:
+			return true
+		case a.File > b.File:
+			return false
+		// a.File == b.File:
+		default:
+			return a.Line < b.Line
+		}
+	})
+	// Non-debugging symbols are listed after functions with debug information.
+	fns = append(fns, nonDebugFns...)
+	return fns, nil
+}
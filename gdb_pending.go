@@ -0,0 +1,59 @@
+package callgraph
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// funcsAfter retrieves debug information about functions of the given binary
+// executable once the traced program reaches the function of g.BreakAfter,
+// including functions of shared libraries loaded at runtime (e.g. plugins
+// loaded using dlopen).
+func (g *GDB) funcsAfter(binPath string) ([]Func, error) {
+	if g.PID != 0 || len(g.Core) > 0 {
+		return nil, errors.Errorf("retrieving functions after %q requires launching the traced program; cannot be combined with attaching to a running process or core dump analysis", g.BreakAfter)
+	}
+	output, err := g.run(binPath, g.funcsAfterScript())
+	if err != nil && !IsPartial(err) {
+		return nil, errors.WithStack(err)
+	}
+	// Example GDB output line:
+	//
+	//    Breakpoint 1, load_plugins (dir=0x555555556004 "plugins") at main.c:42
+	//    Thread 2 "test" hit Breakpoint 1, load_plugins (dir=0x555555556004 "plugins") at main.c:42
+	if !strings.Contains(output, breakpointPrefix+"1, ") {
+		return nil, errors.Errorf("traced program %q never reached function %q", binPath, g.BreakAfter)
+	}
+	fns, err := g.ParseFuncs(output)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fns, nil
+}
+
+// funcsAfterScript returns the GDB command script used to retrieve the
+// functions of the traced program once it reaches the function of
+// g.BreakAfter (see funcsAfter), after which the traced program is killed.
+//
+// Example:
+//
+//    break load_plugins
+//    run '--headless'
+//    info functions
+//    kill
+func (g *GDB) funcsAfterScript() string {
+	input := &bytes.Buffer{}
+	fmt.Fprintf(input, "set width 0\n")
+	fmt.Fprintf(input, "set height 0\n")
+	fmt.Fprintf(input, "set verbose off\n")
+	fmt.Fprintf(input, "set breakpoint pending on\n")
+	fmt.Fprintf(input, "break %s\n", g.BreakAfter)
+	g.inferiorScript(input)
+	fmt.Fprintf(input, "%s\n", g.runCommand())
+	fmt.Fprintf(input, "info functions\n")
+	fmt.Fprintf(input, "kill\n")
+	return input.String()
+}
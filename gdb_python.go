@@ -0,0 +1,250 @@
+package callgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pythonHitPrefix is the prefix of breakpoint hit lines printed by the GDB
+// Python breakpoint script (see GDB.Python), followed by the JSON encoded
+// breakpoint hit.
+//
+// Example output line:
+//
+//    callgraph: {"bp": 2, "frames": [{"func": "bar", "args": "n=23", "file": "test.c", "line": 25}, ...], "more": false}
+const pythonHitPrefix = "callgraph: "
+
+// pythonBreakpointScript is the GDB Python script registering breakpoints of
+// functions, the stop method of which prints the backtrace of each breakpoint
+// hit as a JSON encoded line prefixed by pythonHitPrefix. If returns are
+// recorded, a finish breakpoint is added for each breakpoint hit, which prints
+// the return of the callee to its caller as a JSON encoded line with "return"
+// set. The script is formatted with the Python literals of breakpoint
+// locations, backtrace window size, whether to record threads, resolve caller
+// symbols and record returns, and the maximum number of hits per breakpoint.
+const pythonBreakpointScript = `python
+import json
+import gdb
+
+locations = %s
+window = %d
+record_threads = %s
+resolve_symbols = %s
+record_returns = %s
+hits_per_fn = %d
+
+def frame_args(f):
+    args = []
+    try:
+        block = f.block()
+        while block.function is None and block.superblock is not None:
+            block = block.superblock
+        for sym in block:
+            if sym.is_argument:
+                args.append("%%s=%%s" %% (sym.print_name, sym.value(f)))
+    except RuntimeError:
+        pass
+    return ", ".join(args)
+
+def src_line():
+    try:
+        lines = gdb.execute("frame 0", to_string=True).splitlines()
+    except gdb.error:
+        return ""
+    if len(lines) < 2:
+        return ""
+    return lines[-1]
+
+def frame_info(f, caller):
+    frame = {"func": f.name() or "??", "args": frame_args(f), "inlined": f.type() == gdb.INLINE_FRAME}
+    if caller:
+        frame["pc"] = f.pc()
+    sal = f.find_sal()
+    if sal.symtab is not None:
+        frame["file"] = sal.symtab.filename
+        frame["line"] = sal.line
+    return frame
+
+def write_hit(hit):
+    if record_threads or len(gdb.selected_inferior().threads()) > 1:
+        hit["thread"] = gdb.selected_thread().num
+    gdb.write("%s" + json.dumps(hit) + "\n")
+    gdb.flush()
+
+class CallgraphFinishBreakpoint(gdb.FinishBreakpoint):
+    def __init__(self, frame, bp, callee, more):
+        gdb.FinishBreakpoint.__init__(self, frame, internal=True)
+        self.bp = bp
+        self.callee = callee
+        self.more = more
+
+    def stop(self):
+        caller = frame_info(gdb.newest_frame(), True)
+        write_hit({"bp": self.bp, "frames": [self.callee, caller], "more": self.more, "return": True})
+        return False
+
+    def out_of_scope(self):
+        # Callee frame unwound without returning (e.g. longjmp or exception).
+        pass
+
+class CallgraphBreakpoint(gdb.Breakpoint):
+    hits = 0
+
+    def disable(self):
+        if self.is_valid():
+            self.enabled = False
+
+    def stop(self):
+        if hits_per_fn > 0:
+            # Breakpoints may not be modified from within stop; disable the
+            # breakpoint once its count of hits is reached from the event loop.
+            self.hits += 1
+            if self.hits > hits_per_fn:
+                return False
+            if self.hits == hits_per_fn:
+                gdb.post_event(self.disable)
+        frames = []
+        newest = gdb.newest_frame()
+        f = newest
+        while f is not None and len(frames) < window:
+            frames.append(frame_info(f, len(frames) > 0))
+            f = f.older()
+        hit = {"bp": self.number, "frames": frames, "more": f is not None, "src": src_line()}
+        if resolve_symbols and len(frames) > 1 and frames[1]["func"] == "??":
+            try:
+                hit["symbol"] = gdb.execute("info symbol 0x%%x" %% frames[1]["pc"], to_string=True).strip()
+            except gdb.error:
+                pass
+        write_hit(hit)
+        if record_returns:
+            try:
+                CallgraphFinishBreakpoint(newest, self.number, frames[0], hit["more"])
+            except (ValueError, RuntimeError):
+                # Return of outermost stack frame.
+                pass
+        return False
+
+for location, condition in locations:
+    bp = CallgraphBreakpoint(location)
+    if condition:
+        bp.condition = condition
+end
+`
+
+// pythonScript returns the GDB Python script registering breakpoints of the
+// given functions, recording backtraces of at most n stack frames.
+func (g *GDB) pythonScript(fns []Func, n int) string {
+	// Breakpoint locations and conditions (empty if unconditional). The escape
+	// sequences of Go quoted strings are valid in Python string literals.
+	//
+	//    [["test.c:19", ""], ["render.c:42", "layer == 2"]]
+	var locations []string
+	for _, fn := range fns {
+		location := fmt.Sprintf("%s:%d", fn.File, fn.Line)
+		cond := g.Conditions[funcNameFromSig(fn.Sig)]
+		locations = append(locations, fmt.Sprintf("[%s, %s]", strconv.Quote(location), strconv.Quote(cond)))
+	}
+	list := "[" + strings.Join(locations, ", ") + "]"
+	return fmt.Sprintf(pythonBreakpointScript, list, n, pythonBool(g.Threads), pythonBool(g.ResolveSymbols), pythonBool(g.Returns), g.HitsPerFunc, pythonHitPrefix)
+}
+
+// pythonBool returns the Python literal of the given boolean.
+func pythonBool(v bool) string {
+	if v {
+		return "True"
+	}
+	return "False"
+}
+
+// pythonHit is a JSON encoded breakpoint hit of the GDB Python breakpoint
+// script.
+type pythonHit struct {
+	// Breakpoint number.
+	Breakpoint int `json:"bp"`
+	// Debugger thread number; or 0 if not recorded.
+	Thread int `json:"thread"`
+	// Stack frames of backtrace, starting with the callee (#0).
+	Frames []pythonFrame `json:"frames"`
+	// Stack frames follow beyond the backtrace window.
+	More bool `json:"more"`
+	// Source code of callee source line.
+	Src string `json:"src"`
+	// Symbol lookup of caller call site address (see Options.ResolveSymbols).
+	Symbol string `json:"symbol"`
+	// Return of the callee (#0) to its caller (#1); recorded when the callee
+	// returns (see Options.Returns).
+	Return bool `json:"return"`
+}
+
+// pythonFrame is a JSON encoded stack frame of the GDB Python breakpoint
+// script.
+type pythonFrame struct {
+	// Function name; or "??" if unknown.
+	Func string `json:"func"`
+	// Function arguments.
+	Args string `json:"args"`
+	// Source file name; or empty if unknown.
+	File string `json:"file"`
+	// Line number.
+	Line int `json:"line"`
+	// Program counter of caller stack frames.
+	PC uint64 `json:"pc"`
+	// Function of stack frame was inlined into its caller.
+	Inlined bool `json:"inlined"`
+}
+
+// parsePythonHit parses the given breakpoint hit line of the GDB Python
+// breakpoint script, and returns the corresponding edges of the call graph.
+func (g *GDB) parsePythonHit(line string, index int) ([]Edge, error) {
+	var hit pythonHit
+	if err := json.Unmarshal([]byte(line[len(pythonHitPrefix):]), &hit); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse breakpoint hit line %q", line)
+	}
+	if g.MaxDepth >= 0 && hit.More {
+		// Callee is located deeper than g.MaxDepth levels from the root.
+		return nil, nil
+	}
+	var frames []StackFrame
+	for i, f := range hit.Frames {
+		st := StackFrame{
+			StackFrameNum: i,
+			FuncName:      g.demangleName(f.Func),
+			Args:          f.Args,
+			SrcFile:       g.srcPath(f.File),
+			LineNum:       f.Line,
+			CallSitePC:    f.PC,
+			Inlined:       f.Inlined,
+		}
+		if f.Func == "??" {
+			st.FuncName = UnknownFuncName
+			st.Unknown = true
+		}
+		frames = append(frames, st)
+	}
+	if len(frames) == 0 {
+		dbg.Printf("unable to determine callee of breakpoint hit %q", line)
+		return nil, nil
+	}
+	if g.ResolveSymbols {
+		g.resolveCaller(frames, []string{hit.Symbol})
+	}
+	edges := g.hitEdges([][]StackFrame{frames}, []string{hit.Src}, index, hit.Breakpoint, hit.Thread)
+	if hit.Return {
+		// Return from callee to caller.
+		for i := range edges {
+			edges[i].Src, edges[i].Dst = edges[i].Dst, edges[i].Src
+			edges[i].Kind = EdgeReturn
+		}
+	}
+	return edges, nil
+}
+
+// isPythonHit reports whether the given GDB output line is a breakpoint hit
+// line of the GDB Python breakpoint script.
+func isPythonHit(line string) bool {
+	return strings.HasPrefix(line, pythonHitPrefix)
+}
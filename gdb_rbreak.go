@@ -0,0 +1,204 @@
+package callgraph
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RBreakFuncs retrieves the functions of the given binary executable matching
+// the regular expression of g.RBreak, in order of the breakpoints added by the
+// GDB rbreak command. Functions without debug information are included, as
+// rbreak adds breakpoints to non-debugging symbols as well.
+func (g *GDB) RBreakFuncs(binPath string) ([]Func, error) {
+	output, err := g.run(binPath, g.rbreakFuncsScript())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fns, err := g.parseRBreakFuncs(output)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(fns) == 0 {
+		return nil, errors.Errorf("unable to locate any function matching %q in %q", g.RBreak, binPath)
+	}
+	return fns, nil
+}
+
+// rbreakFuncsScript returns the GDB command script used to retrieve the
+// functions matching g.RBreak (see RBreakFuncs).
+func (g *GDB) rbreakFuncsScript() string {
+	input := &bytes.Buffer{}
+	fmt.Fprintf(input, "set width 0\n")
+	fmt.Fprintf(input, "set height 0\n")
+	fmt.Fprintf(input, "set verbose off\n")
+	fmt.Fprintf(input, "rbreak %s\n", g.RBreak)
+	return input.String()
+}
+
+// parseRBreakFuncs parses the functions of the breakpoints added by the GDB
+// rbreak command, in order of breakpoint numbers.
+//
+// Example GDB output:
+//
+//    Breakpoint 1 at 0x1149: file test.c, line 19.
+//    int foo(int);
+//    Breakpoint 2 at 0x1030
+//    <function, no debug info> puts@plt;
+func (g *GDB) parseRBreakFuncs(output string) ([]Func, error) {
+	lines := strings.Split(output, "\n")
+	var fns []Func
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if !strings.HasPrefix(line, breakpointPrefix) || i+1 >= len(lines) {
+			continue
+		}
+		fields := strings.Fields(line[len(breakpointPrefix):])
+		if len(fields) == 0 {
+			continue
+		}
+		breakNr, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if breakNr != len(fns)+1 {
+			return nil, errors.Errorf("unable to parse rbreak output; expected breakpoint %d, got %d", len(fns)+1, breakNr)
+		}
+		i++
+		sig := strings.TrimSpace(lines[i])
+		// Non-debugging symbol.
+		const nonDebugPrefix = "<function, no debug info> "
+		if strings.HasPrefix(sig, nonDebugPrefix) {
+			sym := g.demangleName(strings.TrimSuffix(sig[len(nonDebugPrefix):], ";"))
+			fn := Func{
+				Name:     sym,
+				Sig:      sym,
+				NonDebug: true,
+			}
+			fns = append(fns, fn)
+			continue
+		}
+		sig = g.demangleName(sig)
+		fn := Func{
+			Name: funcNameFromSig(sig),
+			Sig:  sig,
+		}
+		// Source location of breakpoint with single location.
+		//
+		//    ": file test.c, line 19."
+		const filePrefix = ": file "
+		if pos := strings.Index(line, filePrefix); pos != -1 {
+			loc := strings.TrimSuffix(line[pos+len(filePrefix):], ".")
+			if end := strings.LastIndex(loc, ", line "); end != -1 {
+				lineNum, err := strconv.Atoi(loc[end+len(", line "):])
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				fn.File = g.srcPath(loc[:end])
+				fn.Line = lineNum
+			}
+		}
+		fns = append(fns, fn)
+	}
+	return fns, nil
+}
+
+// rbreakScript writes the GDB commands adding breakpoints of the functions
+// matching g.RBreak to w, with a single "commands" block shared by the
+// breakpoints recording a backtrace of n stack frames. The given functions are
+// the breakpoints added by rbreak, in order. Breakpoints with the given
+// breakpoint numbers are deleted (e.g. failed breakpoint insertions).
+//
+// Example:
+//
+//    rbreak ^render_
+//    condition 2 layer == 2
+//    delete 3
+//    commands 1-2 4-7
+//    backtrace 2
+//    continue
+//    end
+func (g *GDB) rbreakScript(w *bytes.Buffer, fns []Func, deleted map[int]bool, n int) {
+	fmt.Fprintf(w, "rbreak %s\n", g.RBreak)
+	var breakNrs []int
+	for i, fn := range fns {
+		breakNr := i + 1
+		if deleted[breakNr] {
+			fmt.Fprintf(w, "delete %d\n", breakNr)
+			continue
+		}
+		if cond, ok := g.Conditions[fn.Name]; ok {
+			fmt.Fprintf(w, "condition %d %s\n", breakNr, cond)
+		}
+		breakNrs = append(breakNrs, breakNr)
+	}
+	if len(breakNrs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "commands %s\n", breakpointRanges(breakNrs))
+	g.hookScript(w, n)
+	fmt.Fprintf(w, "end\n")
+	g.hitLimitScript(w, breakNrs)
+}
+
+// breakpointRanges returns the GDB breakpoint list of the given sorted
+// breakpoint numbers, with consecutive breakpoint numbers combined into ranges
+// (e.g. "1-2 4-7 9").
+func breakpointRanges(breakNrs []int) string {
+	var ranges []string
+	for i := 0; i < len(breakNrs); {
+		j := i
+		for j+1 < len(breakNrs) && breakNrs[j+1] == breakNrs[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(breakNrs[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", breakNrs[i], breakNrs[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, " ")
+}
+
+// traceRBreak traces the call graph of the functions matching g.RBreak, with
+// breakpoints added by the GDB rbreak command. The given functions are the
+// breakpoints added by rbreak, in order (see RBreakFuncs).
+//
+// Breakpoints which GDB fails to insert are deleted with a warning, and the
+// trace is restarted. As rbreak adds the same breakpoints on each restart,
+// breakpoint numbers remain stable.
+func (g *GDB) traceRBreak(binPath string, fns []Func) ([]Edge, error) {
+	if g.Python {
+		return nil, errors.Errorf("support for rbreak not yet implemented for GDB Python mode")
+	}
+	deleted := make(map[int]bool)
+	for {
+		edges, failed, err := g.trace(binPath, g.traceScript(fns, deleted), fns)
+		var breakNrs []int
+		for breakNr := range failed {
+			if !deleted[breakNr] && breakNr <= len(fns) {
+				breakNrs = append(breakNrs, breakNr)
+			}
+		}
+		if len(breakNrs) == 0 {
+			// No failed breakpoints, or failed breakpoints not correlated to
+			// functions.
+			return edges, err
+		}
+		sort.Ints(breakNrs)
+		for _, breakNr := range breakNrs {
+			fn := fns[breakNr-1]
+			log.Printf("warning: unable to insert breakpoint %d of %q (%s); skipping function", breakNr, fn.Sig, failed[breakNr])
+			deleted[breakNr] = true
+		}
+		if len(deleted) == len(fns) {
+			return nil, errors.Errorf("unable to insert breakpoints of any function in %q", binPath)
+		}
+	}
+}
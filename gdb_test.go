@@ -0,0 +1,490 @@
+package callgraph
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFuncs(t *testing.T) {
+	const output = `All defined functions:
+
+File test.cpp:
+9:	int main(int, char **);
+14:	int ns::Foo::get(int);
+20:	static void (anonymous namespace)::anon(void);
+
+Non-debugging symbols:
+0x0000000000001000  _init
+`
+	want := []Func{
+		{Name: "main", File: "test.cpp", Line: 9, Sig: "int main(int, char **);"},
+		{Name: "ns::Foo::get", File: "test.cpp", Line: 14, Sig: "int ns::Foo::get(int);"},
+		{Name: "(anonymous namespace)::anon", File: "test.cpp", Line: 20, Sig: "static void (anonymous namespace)::anon(void);"},
+		{Name: "_init", Sig: "_init", NonDebug: true},
+	}
+	g := NewGDB()
+	got, err := g.ParseFuncs(output)
+	if err != nil {
+		t.Fatalf("unable to parse functions; %+v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("number of functions mismatch; expected %d, got %d (%v)", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("function %d mismatch; expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestParseStrackTrace(t *testing.T) {
+	golden := []struct {
+		line string
+		want StackFrame
+	}{
+		// Callee stack frame.
+		{
+			line: "#0  foo (n=23) at test.c:19",
+			want: StackFrame{StackFrameNum: 0, FuncName: "foo", Args: "n=23", SrcFile: "test.c", LineNum: 19},
+		},
+		// Caller stack frame with call site address.
+		{
+			line: "#1  0x0000555555555171 in foo (n=23) at test.c:19",
+			want: StackFrame{StackFrameNum: 1, FuncName: "foo", Args: "n=23", SrcFile: "test.c", LineNum: 19, CallSitePC: 0x555555555171},
+		},
+		// Templated function name containing spaces and commas.
+		{
+			line: "#1  0x0000555555555bd4 in std::map<int, std::string>::insert (this=0x7fffffffe0a0, v=...) at map.h:55",
+			want: StackFrame{StackFrameNum: 1, FuncName: "std::map<int, std::string>::insert", Args: "this=0x7fffffffe0a0, v=...", SrcFile: "map.h", LineNum: 55, CallSitePC: 0x555555555bd4},
+		},
+		// Nested template arguments.
+		{
+			line: "#0  std::vector<std::pair<int, int>, std::allocator<std::pair<int, int> > >::push_back (this=0x7fffffffe0c0, __x=...) at /usr/include/c++/13/bits/stl_vector.h:1281",
+			want: StackFrame{StackFrameNum: 0, FuncName: "std::vector<std::pair<int, int>, std::allocator<std::pair<int, int> > >::push_back", Args: "this=0x7fffffffe0c0, __x=...", SrcFile: "/usr/include/c++/13/bits/stl_vector.h", LineNum: 1281},
+		},
+		// Function pointer argument.
+		{
+			line: "#0  apply (f=0x401136 <handler(int)>, n=1) at fp.c:7",
+			want: StackFrame{StackFrameNum: 0, FuncName: "apply", Args: "f=0x401136 <handler(int)>, n=1", SrcFile: "fp.c", LineNum: 7},
+		},
+		// Parentheses inside arguments (casts and string contents).
+		{
+			line: `#0  log_msg (p=(int *) 0x7fffffffe0ac, msg=0x402004 "done (ok)") at log.c:12`,
+			want: StackFrame{StackFrameNum: 0, FuncName: "log_msg", Args: `p=(int *) 0x7fffffffe0ac, msg=0x402004 "done (ok)"`, SrcFile: "log.c", LineNum: 12},
+		},
+		// Unbalanced parenthesis inside a quoted argument.
+		{
+			line: `#0  put (c=40 '(') at put.c:4`,
+			want: StackFrame{StackFrameNum: 0, FuncName: "put", Args: `c=40 '('`, SrcFile: "put.c", LineNum: 4},
+		},
+		// Operator function.
+		{
+			line: "#0  Vec::operator<< (this=0x7fffffffe0b0, n=3) at vec.cc:8",
+			want: StackFrame{StackFrameNum: 0, FuncName: "Vec::operator<<", Args: "this=0x7fffffffe0b0, n=3", SrcFile: "vec.cc", LineNum: 8},
+		},
+		// Unknown function.
+		{
+			line: "#1  0x00007ffff7a52000 in ?? ()",
+			want: StackFrame{StackFrameNum: 1, FuncName: UnknownFuncName, Unknown: true, CallSitePC: 0x7ffff7a52000},
+		},
+	}
+	g := NewGDB()
+	for _, gold := range golden {
+		got, err := g.parseStrackTrace(gold.line)
+		if err != nil {
+			t.Errorf("%q: unable to parse stack frame; %v", gold.line, err)
+			continue
+		}
+		if got != gold.want {
+			t.Errorf("%q: stack frame mismatch; expected %+v, got %+v", gold.line, gold.want, got)
+		}
+	}
+}
+
+func TestScanBalanced(t *testing.T) {
+	golden := []struct {
+		s    string
+		want int
+	}{
+		{s: "(n=23) at test.c:19", want: len("(n=23)")},
+		{s: "((a)(b)) rest", want: len("((a)(b))")},
+		{s: `(s="a)b") at x.c:1`, want: len(`(s="a)b")`)},
+		{s: `(s="a\")b") at x.c:1`, want: len(`(s="a\")b")`)},
+		{s: "(n=23", want: -1},
+		{s: "n=23)", want: -1},
+	}
+	for _, g := range golden {
+		got := scanBalanced(g.s)
+		if got != g.want {
+			t.Errorf("%q: end position mismatch; expected %d, got %d", g.s, g.want, got)
+		}
+	}
+}
+
+func TestParseTraceBacktrace(t *testing.T) {
+	const output = `Breakpoint 1 at 0x1189: file test.c, line 31.
+Breakpoint 1, baz (n=23) at test.c:31
+31	  return;
+#0  baz (n=23) at test.c:31
+#1  0x0000555555555189 in bar (n=23) at test.c:25
+#2  0x0000555555555171 in foo (n=23) at test.c:19
+#3  0x0000555555555152 in main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+`
+	golden := []struct {
+		backtrace int
+		want      []string
+	}{
+		// Immediate caller only.
+		{backtrace: 2, want: []string{"bar -> baz"}},
+		// Transitive callers within the backtrace window.
+		{backtrace: 3, want: []string{"bar -> baz", "foo -> bar"}},
+		{backtrace: 4, want: []string{"bar -> baz", "foo -> bar", "main -> foo"}},
+		// Backtrace window larger than the backtrace.
+		{backtrace: 10, want: []string{"bar -> baz", "foo -> bar", "main -> foo"}},
+	}
+	fns := []Func{{Name: "baz", File: "test.c", Line: 31, Sig: "static void baz(int);"}}
+	for _, gold := range golden {
+		g := NewGDB()
+		g.Backtrace = gold.backtrace
+		edges, err := g.ParseTrace(output, fns)
+		if err != nil {
+			t.Errorf("backtrace %d: unable to parse trace; %+v", gold.backtrace, err)
+			continue
+		}
+		got := edgePairs(edges)
+		if !reflect.DeepEqual(got, gold.want) {
+			t.Errorf("backtrace %d: edges mismatch; expected %q, got %q", gold.backtrace, gold.want, got)
+		}
+	}
+}
+
+func TestBacktraceEdges(t *testing.T) {
+	frames := []StackFrame{
+		{StackFrameNum: 0, FuncName: "baz"},
+		{StackFrameNum: 1, FuncName: "bar"},
+		{StackFrameNum: 2, FuncName: "foo"},
+	}
+	want := []string{"bar -> baz", "foo -> bar"}
+	if got := edgePairs(backtraceEdges(frames)); !reflect.DeepEqual(got, want) {
+		t.Errorf("edges mismatch; expected %q, got %q", want, got)
+	}
+	// Root.
+	want = []string{" -> main"}
+	if got := edgePairs(backtraceEdges([]StackFrame{{FuncName: "main"}})); !reflect.DeepEqual(got, want) {
+		t.Errorf("edges mismatch; expected %q, got %q", want, got)
+	}
+}
+
+// edgePairs returns the caller/callee function name pairs of the given edges,
+// in "caller -> callee" form.
+func edgePairs(edges []Edge) []string {
+	var pairs []string
+	for _, edge := range edges {
+		pairs = append(pairs, edge.Src.FuncName+" -> "+edge.Dst.FuncName)
+	}
+	return pairs
+}
+
+func TestSameBaseName(t *testing.T) {
+	const output = `All defined functions:
+
+File src/a/util.c:
+3:	int parse_a(int);
+
+File src/b/util.c:
+5:	int parse_b(int);
+`
+	g := NewGDB()
+	fns, err := g.ParseFuncs(output)
+	if err != nil {
+		t.Fatalf("unable to parse functions; %+v", err)
+	}
+	wantFiles := map[string]string{"parse_a": "src/a/util.c", "parse_b": "src/b/util.c"}
+	for _, fn := range fns {
+		if fn.File != wantFiles[fn.Name] {
+			t.Errorf("source file mismatch of %q; expected %q, got %q", fn.Name, wantFiles[fn.Name], fn.File)
+		}
+	}
+	// Breakpoints are added using the full source file path.
+	script := g.traceScript(fns, nil)
+	for _, want := range []string{"break src/a/util.c:3\n", "break src/b/util.c:5\n"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("missing breakpoint %q in GDB command script %q", want, script)
+		}
+	}
+	// Edges are attributed to the source file of their stack frames.
+	const trace = `Breakpoint 1, parse_a (n=1) at src/a/util.c:3
+#0  parse_a (n=1) at src/a/util.c:3
+#1  0x0000555555555152 in main () at main.c:7
+Breakpoint 2, parse_b (n=2) at src/b/util.c:5
+#0  parse_b (n=2) at src/b/util.c:5
+#1  0x0000555555555160 in main () at main.c:8
+`
+	edges, err := g.ParseTrace(trace, fns)
+	if err != nil {
+		t.Fatalf("unable to parse trace; %+v", err)
+	}
+	if len(edges) != 2 {
+		t.Fatalf("number of edges mismatch; expected 2, got %d", len(edges))
+	}
+	for _, edge := range edges {
+		if edge.Dst.SrcFile != wantFiles[edge.Dst.FuncName] {
+			t.Errorf("source file mismatch of %q; expected %q, got %q", edge.Dst.FuncName, wantFiles[edge.Dst.FuncName], edge.Dst.SrcFile)
+		}
+	}
+}
+
+func TestParseTraceNoSource(t *testing.T) {
+	const output = `Breakpoint 1, foo (n=23) at test.c:19
+#0  foo (n=23) at test.c:19
+#1  0x0000555555555152 in main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+Breakpoint 2, bar (n=23) at test.c:25
+25	  baz(n);
+#0  bar (n=23) at test.c:25
+#1  0x0000555555555171 in foo (n=23) at test.c:19
+Breakpoint 3, cb (n=23) at test.c:40
+#0  cb (n=23) at test.c:40
+#1  0x00007ffff7c29d90 in __libc_start_call_main () from /lib/x86_64-linux-gnu/libc.so.6
+Breakpoint 4, baz (n=23) at test.c:31
+`
+	fns := []Func{{Name: "foo", File: "test.c", Line: 19, Sig: "static void foo(int);"}}
+	edges, err := NewGDB().ParseTrace(output, fns)
+	if err != nil {
+		t.Fatalf("unable to parse trace; %+v", err)
+	}
+	want := []Edge{
+		// Source code line missing.
+		{
+			Src:   StackFrame{StackFrameNum: 1, FuncName: "main", Args: "argc=1, argv=0x7fffffffe6a8", SrcFile: "test.c", LineNum: 11, CallSitePC: 0x555555555152},
+			Dst:   StackFrame{StackFrameNum: 0, FuncName: "foo", Args: "n=23", SrcFile: "test.c", LineNum: 19},
+			Index: 0, Breakpoint: 1,
+		},
+		// Source code line present.
+		{
+			Src:     StackFrame{StackFrameNum: 1, FuncName: "foo", Args: "n=23", SrcFile: "test.c", LineNum: 19, CallSitePC: 0x555555555171},
+			Dst:     StackFrame{StackFrameNum: 0, FuncName: "bar", Args: "n=23", SrcFile: "test.c", LineNum: 25},
+			SrcLine: "25\t  baz(n);",
+			Index:   1, Breakpoint: 2,
+		},
+		// Caller without source location (i.e. no file:line).
+		{
+			Src:   StackFrame{StackFrameNum: 1, FuncName: "__libc_start_call_main", CallSitePC: 0x7ffff7c29d90},
+			Dst:   StackFrame{StackFrameNum: 0, FuncName: "cb", Args: "n=23", SrcFile: "test.c", LineNum: 40},
+			Index: 2, Breakpoint: 3,
+		},
+		// Truncated breakpoint output without stack frames is ignored.
+	}
+	if len(edges) != len(want) {
+		t.Fatalf("number of edges mismatch; expected %d, got %d (%+v)", len(want), len(edges), edges)
+	}
+	for i := range want {
+		if edges[i] != want[i] {
+			t.Errorf("edge %d mismatch; expected %+v, got %+v", i, want[i], edges[i])
+		}
+	}
+}
+
+func TestIsSrcLine(t *testing.T) {
+	golden := []struct {
+		line    string
+		lineNum int
+		want    bool
+	}{
+		{line: "25\t  baz(n);", lineNum: 25, want: true},
+		{line: "25      baz(n);", lineNum: 25, want: true},
+		// Line number mismatch.
+		{line: "251\t  baz(n);", lineNum: 25, want: false},
+		// Stack frame line.
+		{line: "#0  bar (n=23) at test.c:25", lineNum: 25, want: false},
+		{line: "", lineNum: 25, want: false},
+	}
+	for _, g := range golden {
+		if got := isSrcLine(g.line, g.lineNum); got != g.want {
+			t.Errorf("%q (line %d): source line mismatch; expected %v, got %v", g.line, g.lineNum, g.want, got)
+		}
+	}
+}
+
+func TestParseTraceInlined(t *testing.T) {
+	// Backtrace of -O2 build, where baz was inlined into bar.
+	const output = `Breakpoint 1, baz (n=23) at test.c:31
+31	  return;
+#0  baz (n=23) at test.c:31
+#1  bar (n=23) at test.c:25
+#2  0x0000555555555171 in foo (n=23) at test.c:19
+`
+	g := NewGDB()
+	g.Backtrace = 3
+	edges, err := g.ParseTrace(output, nil)
+	if err != nil {
+		t.Fatalf("unable to parse trace; %+v", err)
+	}
+	want := []struct {
+		pair    string
+		inlined bool
+	}{
+		{pair: "bar -> baz", inlined: true},
+		{pair: "foo -> bar", inlined: false},
+	}
+	if len(edges) != len(want) {
+		t.Fatalf("number of edges mismatch; expected %d, got %d", len(want), len(edges))
+	}
+	for i, edge := range edges {
+		if pair := edgePairs(edges[i : i+1])[0]; pair != want[i].pair {
+			t.Errorf("edge %d mismatch; expected %q, got %q", i, want[i].pair, pair)
+		}
+		if edge.Dst.Inlined != want[i].inlined {
+			t.Errorf("edge %d (%s): inlined mismatch; expected %v, got %v", i, want[i].pair, want[i].inlined, edge.Dst.Inlined)
+		}
+	}
+}
+
+func TestMarkInlined(t *testing.T) {
+	frames := []StackFrame{
+		{StackFrameNum: 0, FuncName: "baz"},
+		{StackFrameNum: 1, FuncName: "bar"},
+		{StackFrameNum: 2, FuncName: "foo", CallSitePC: 0x555555555171},
+		{StackFrameNum: 3, FuncName: "main", CallSitePC: 0x555555555152},
+	}
+	markInlined(frames)
+	want := []bool{true, false, false, false}
+	for i, frame := range frames {
+		if frame.Inlined != want[i] {
+			t.Errorf("frame #%d (%s): inlined mismatch; expected %v, got %v", i, frame.FuncName, want[i], frame.Inlined)
+		}
+	}
+}
+
+func TestScanFuncName(t *testing.T) {
+	golden := []struct {
+		s        string
+		funcName string
+		rest     string
+	}{
+		{s: "foo (n=23) at test.c:19", funcName: "foo", rest: "(n=23) at test.c:19"},
+		// Anonymous namespace.
+		{s: "(anonymous namespace)::f (x=1) at anon.cc:3", funcName: "(anonymous namespace)::f", rest: "(x=1) at anon.cc:3"},
+		{s: "ns::(anonymous namespace)::Impl::run (this=0x4052a0) at impl.cc:12", funcName: "ns::(anonymous namespace)::Impl::run", rest: "(this=0x4052a0) at impl.cc:12"},
+		// Lambdas.
+		{s: "main::{lambda()#1}::operator() (__closure=0x7fffffffe0a7) at lambda.cc:5", funcName: "main::{lambda()#1}::operator()", rest: "(__closure=0x7fffffffe0a7) at lambda.cc:5"},
+		{s: "main::{lambda(int)#1}::operator() (__closure=0x7fffffffe0a7, x=1) at lambda.cc:9", funcName: "main::{lambda(int)#1}::operator()", rest: "(__closure=0x7fffffffe0a7, x=1) at lambda.cc:9"},
+		{s: "Widget::draw() const::{lambda(int, int)#2}::operator() (__closure=0x7fffffffe0b0, a=1, b=2) at widget.cc:20", funcName: "Widget::draw() const::{lambda(int, int)#2}::operator()", rest: "(__closure=0x7fffffffe0b0, a=1, b=2) at widget.cc:20"},
+		{s: "(anonymous namespace)::g()::{lambda()#1}::operator() (__closure=0x0) at anon.cc:8", funcName: "(anonymous namespace)::g()::{lambda()#1}::operator()", rest: "(__closure=0x0) at anon.cc:8"},
+		// Missing argument list.
+		{s: "foo", funcName: "", rest: ""},
+	}
+	for _, g := range golden {
+		funcName, rest, ok := scanFuncName(g.s)
+		if ok != (len(g.funcName) > 0) {
+			t.Errorf("%q: success mismatch; expected %v, got %v", g.s, len(g.funcName) > 0, ok)
+			continue
+		}
+		if funcName != g.funcName || rest != g.rest {
+			t.Errorf("%q: mismatch; expected (%q, %q), got (%q, %q)", g.s, g.funcName, g.rest, funcName, rest)
+		}
+	}
+}
+
+func TestParseTraceIndex(t *testing.T) {
+	const output = `Breakpoint 1 at 0x1139: file test.c, line 11.
+Breakpoint 2 at 0x1149: file test.c, line 19.
+Breakpoint 3 at 0x1161: file test.c, line 25.
+Breakpoint 1, main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+#0  main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+Breakpoint 4 (plugin.c:12) pending.
+Breakpoint 2, foo (n=23) at test.c:19
+#0  foo (n=23) at test.c:19
+#1  0x0000555555555152 in main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+Breakpoint 3, bar (n=23) at test.c:25
+#0  bar (n=23) at test.c:25
+#1  0x0000555555555171 in foo (n=23) at test.c:19
+`
+	fns := []Func{{Name: "main", File: "test.c", Line: 11, Sig: "int main(int, char **);"}}
+	g := NewGDB()
+	var progress Progress
+	g.OnProgress = func(p Progress) {
+		progress = p
+	}
+	edges, err := g.ParseTrace(output, fns)
+	if err != nil {
+		t.Fatalf("unable to parse trace; %+v", err)
+	}
+	if len(edges) != 3 {
+		t.Fatalf("number of edges mismatch; expected 3, got %d", len(edges))
+	}
+	// Breakpoint hits are indexed in order, without gaps of added breakpoints.
+	for i, edge := range edges {
+		if edge.Index != i {
+			t.Errorf("edge %d (%s -> %s): index mismatch; expected %d, got %d", i, edge.Src.FuncName, edge.Dst.FuncName, i, edge.Index)
+		}
+	}
+	want := Progress{Breakpoints: 4, Hits: 3}
+	if progress != want {
+		t.Errorf("progress mismatch; expected %+v, got %+v", want, progress)
+	}
+	// Added breakpoints which are never hit.
+	const unhit = `Breakpoint 1 at 0x1139: file test.c, line 11.
+[Inferior 1 (process 4242) exited normally]
+`
+	edges, err = NewGDB().ParseTrace(unhit, fns)
+	if err != nil {
+		t.Errorf("unable to parse trace without breakpoint hits; %+v", err)
+	}
+	if len(edges) != 0 {
+		t.Errorf("number of edges mismatch; expected 0, got %d", len(edges))
+	}
+}
+
+func TestTraceRunError(t *testing.T) {
+	fns := []Func{{Name: "main", File: "test.c", Line: 11, Sig: "int main(int, char **);"}}
+	// GDB fails to run; the run error is reported, not the locale error.
+	g := NewGDB()
+	g.Path = "/nonexistent/gdb"
+	_, _, err := g.trace("", "", fns)
+	if err == nil {
+		t.Fatalf("expected error of missing GDB executable")
+	}
+	if msg := err.Error(); !strings.Contains(msg, g.Path) || strings.Contains(msg, "localized") {
+		t.Errorf("error mismatch; expected run error of %q, got %q", g.Path, msg)
+	}
+	// GDB exits cleanly without any breakpoint output; the locale error is
+	// reported.
+	path, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("unable to locate true executable")
+	}
+	g = NewGDB()
+	g.Path = path
+	_, _, err = g.trace("", "", fns)
+	if err == nil || !strings.Contains(err.Error(), "localized") {
+		t.Errorf("error mismatch; expected locale error, got %v", err)
+	}
+}
+
+func TestParsePythonHitRoot(t *testing.T) {
+	// Breakpoint hit in main, without older stack frames (backtrace past-main
+	// off).
+	const output = `Breakpoint 1, main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+11	  foo(23);
+#0  main (argc=1, argv=0x7fffffffe6a8) at test.c:11
+`
+	const line = `callgraph: {"bp": 1, "thread": 0, "frames": [{"func": "main", "args": "argc=1, argv=0x7fffffffe6a8", "file": "test.c", "line": 11, "pc": 0, "inlined": false}], "more": false, "src": "11\t  foo(23);"}`
+	fns := []Func{{Name: "main", File: "test.c", Line: 11, Sig: "int main(int, char **);"}}
+	g := NewGDB()
+	want, err := g.ParseTrace(output, fns)
+	if err != nil {
+		t.Fatalf("unable to parse trace; %+v", err)
+	}
+	if len(want) != 1 || want[0].Src.FuncName != "" || want[0].Dst.FuncName != "main" {
+		t.Fatalf("root edge mismatch; expected -> main, got %+v", want)
+	}
+	got, err := g.parsePythonHit(line, 0)
+	if err != nil {
+		t.Fatalf("unable to parse Python breakpoint hit; %+v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("edges mismatch with GDB text output; expected %+v, got %+v", want, got)
+	}
+}
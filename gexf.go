@@ -0,0 +1,173 @@
+package callgraph
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// WriteGEXF writes the given call graph to w in GEXF format, for import into
+// Gephi. Repeated calls between the same caller and callee are collapsed into a
+// single edge, weighted by call count. The graph is dynamic, with each node and
+// edge starting at the time of the breakpoint hit in which it was first seen
+// (see gexfTimes), for use with the Gephi timeline.
+//
+// Example output:
+//
+//    <gexf xmlns="http://www.gexf.net/1.2draft" version="1.2">
+//       <graph mode="dynamic" defaultedgetype="directed" timeformat="double">
+//          <nodes>
+//             <node id="n0" label="main" start="0"></node>
+//             <node id="n1" label="foo" start="1"></node>
+//          </nodes>
+//          <edges>
+//             <edge id="e0" source="n0" target="n1" weight="1" label="n=23" start="1"></edge>
+//          </edges>
+//       </graph>
+//    </gexf>
+func WriteGEXF(w io.Writer, edges []Edge) error {
+	ids := newNodeIDs(edges)
+	times := gexfTimes(edges)
+	// Time of breakpoint hit in which each node was first seen, keyed by
+	// function name.
+	starts := make(map[string]int)
+	// Time of breakpoint hit in which each edge was first seen.
+	edgeStarts := make(map[edgeKey]int)
+	zero := StackFrame{}
+	for i, edge := range edges {
+		for _, st := range []StackFrame{edge.Src, edge.Dst} {
+			if st == zero {
+				continue
+			}
+			if _, ok := starts[st.FuncName]; !ok {
+				starts[st.FuncName] = times[i]
+			}
+		}
+		k := edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}
+		if _, ok := edgeStarts[k]; !ok {
+			edgeStarts[k] = times[i]
+		}
+	}
+	g := gexfGraph{
+		Mode:            "dynamic",
+		DefaultEdgeType: "directed",
+		TimeFormat:      "double",
+	}
+	for _, name := range ids.names {
+		node := gexfNode{
+			ID:    ids.id(name),
+			Label: name,
+			Start: strconv.Itoa(starts[name]),
+		}
+		g.Nodes = append(g.Nodes, node)
+	}
+	for _, edge := range CollapseEdges(edges) {
+		if edge.Src == zero {
+			// Caller information missing; node already declared.
+			continue
+		}
+		e := gexfEdge{
+			ID:     "e" + strconv.Itoa(len(g.Edges)),
+			Source: ids.id(edge.Src.FuncName),
+			Target: ids.id(edge.Dst.FuncName),
+			Weight: edge.Count,
+			Label:  edge.Dst.Args,
+			Start:  strconv.Itoa(edgeStarts[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}]),
+		}
+		g.Edges = append(g.Edges, e)
+	}
+	doc := gexfDoc{
+		Xmlns:   "http://www.gexf.net/1.2draft",
+		Version: "1.2",
+		Graph:   g,
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return errors.WithStack(err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// gexfTimes returns the time of the breakpoint hit of each of the given edges,
+// which are expected in execution order. The time is the index of the
+// breakpoint hit (see Edge.Index), offset by the breakpoint hits of preceding
+// traced program runs (see Edge.Run) and merged call graphs, whose indices
+// start over at 0; thus the timelines of runs do not overlap.
+func gexfTimes(edges []Edge) []int {
+	times := make([]int, len(edges))
+	// Time offset of current run.
+	offset := 0
+	// Maximum time of preceding edges.
+	max := -1
+	for i, edge := range edges {
+		if i > 0 && (edge.Run != edges[i-1].Run || edge.Index < edges[i-1].Index) {
+			// Start of next run.
+			offset = max + 1
+		}
+		times[i] = offset + edge.Index
+		if times[i] > max {
+			max = times[i]
+		}
+	}
+	return times
+}
+
+// gexfDoc is a GEXF document.
+type gexfDoc struct {
+	XMLName xml.Name `xml:"gexf"`
+	// GEXF namespace.
+	Xmlns string `xml:"xmlns,attr"`
+	// GEXF version.
+	Version string `xml:"version,attr"`
+	// Call graph.
+	Graph gexfGraph `xml:"graph"`
+}
+
+// gexfGraph is a GEXF graph.
+type gexfGraph struct {
+	// Graph mode (static or dynamic).
+	Mode string `xml:"mode,attr"`
+	// Default edge type.
+	DefaultEdgeType string `xml:"defaultedgetype,attr"`
+	// Format of start and end times of dynamic graphs.
+	TimeFormat string `xml:"timeformat,attr"`
+	// Graph nodes.
+	Nodes []gexfNode `xml:"nodes>node"`
+	// Graph edges.
+	Edges []gexfEdge `xml:"edges>edge"`
+}
+
+// gexfNode is a GEXF node.
+type gexfNode struct {
+	// Node ID.
+	ID string `xml:"id,attr"`
+	// Node label.
+	Label string `xml:"label,attr"`
+	// Start time of node.
+	Start string `xml:"start,attr"`
+}
+
+// gexfEdge is a GEXF edge.
+type gexfEdge struct {
+	// Edge ID.
+	ID string `xml:"id,attr"`
+	// Source node ID.
+	Source string `xml:"source,attr"`
+	// Target node ID.
+	Target string `xml:"target,attr"`
+	// Edge weight.
+	Weight int `xml:"weight,attr"`
+	// Edge label.
+	Label string `xml:"label,attr,omitempty"`
+	// Start time of edge.
+	Start string `xml:"start,attr"`
+}
@@ -0,0 +1,58 @@
+package callgraph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestWriteGEXFRuns(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	// Breakpoint hit indices start over in each run.
+	edges := []Edge{
+		{Dst: main, Run: 0, Index: 0},
+		{Src: main, Dst: foo, Run: 0, Index: 1},
+		{Src: main, Dst: foo, Run: 0, Index: 2},
+		{Dst: main, Run: 1, Index: 0},
+		{Src: main, Dst: bar, Run: 1, Index: 1},
+	}
+	if got, want := gexfTimes(edges), []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("times mismatch; expected %v, got %v", want, got)
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteGEXF(buf, edges); err != nil {
+		t.Fatalf("unable to write GEXF; %+v", err)
+	}
+	var doc gexfDoc
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("unable to parse GEXF; %v", err)
+	}
+	nodeStarts := make(map[string]string)
+	for _, node := range doc.Graph.Nodes {
+		nodeStarts[node.Label] = node.Start
+	}
+	wantNodes := map[string]string{"main": "0", "foo": "1", "bar": "4"}
+	if !reflect.DeepEqual(nodeStarts, wantNodes) {
+		t.Errorf("node start times mismatch; expected %v, got %v", wantNodes, nodeStarts)
+	}
+	var edgeStarts []string
+	for _, edge := range doc.Graph.Edges {
+		edgeStarts = append(edgeStarts, edge.Start)
+	}
+	if want := []string{"1", "4"}; !reflect.DeepEqual(edgeStarts, want) {
+		t.Errorf("edge start times mismatch; expected %v, got %v", want, edgeStarts)
+	}
+	// Merged binaries of the same run.
+	merged := []Edge{
+		{Dst: main, Index: 0},
+		{Src: main, Dst: foo, Index: 1},
+		{Dst: main, Index: 0},
+		{Src: main, Dst: bar, Index: 1},
+	}
+	if got, want := gexfTimes(merged), []int{0, 1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("times of merged binaries mismatch; expected %v, got %v", want, got)
+	}
+}
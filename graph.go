@@ -0,0 +1,742 @@
+package callgraph
+
+// CountedEdge is a call graph edge annotated with the number of times it was
+// observed.
+type CountedEdge struct {
+	// Edge of first occurrence.
+	Edge
+	// Number of occurrences of the edge.
+	Count int
+	// Differing callee argument values were observed between occurrences; the
+	// argument string of the first occurrence is kept in Dst.Args.
+	MixedArgs bool
+	// Number of distinct traced program runs that exercised the edge.
+	Runs int
+}
+
+// CollapseEdges collapses edges of the same kind sharing the same caller and
+// callee function names into a single counted edge (see CountedEdge). The
+// order of first occurrence is preserved.
+func CollapseEdges(edges []Edge) []CountedEdge {
+	type key struct {
+		src, dst string
+		kind     EdgeKind
+	}
+	// Index into counted, keyed by caller/callee function name pair and edge
+	// kind.
+	index := make(map[key]int)
+	// Runs exercising each edge, keyed by index into counted.
+	runs := make(map[int]map[int]bool)
+	var counted []CountedEdge
+	for _, edge := range edges {
+		k := key{src: edge.Src.FuncName, dst: edge.Dst.FuncName, kind: edge.Kind}
+		if i, ok := index[k]; ok {
+			c := &counted[i]
+			c.Count++
+			if c.Dst.Args != edge.Dst.Args {
+				c.MixedArgs = true
+			}
+			if !runs[i][edge.Run] {
+				runs[i][edge.Run] = true
+				c.Runs++
+			}
+			continue
+		}
+		index[k] = len(counted)
+		runs[len(counted)] = map[int]bool{edge.Run: true}
+		c := CountedEdge{
+			Edge:  edge,
+			Count: 1,
+			Runs:  1,
+		}
+		counted = append(counted, c)
+	}
+	return counted
+}
+
+// pruneDepth prunes edges of callees whose shortest path from a root exceeds
+// maxDepth. Depth 0 means just the roots.
+func pruneDepth(edges []Edge, maxDepth int) []Edge {
+	depths := callDepths(edges)
+	var pruned []Edge
+	for _, edge := range edges {
+		if depth, ok := depths[edge.Dst.FuncName]; ok && depth > maxDepth {
+			continue
+		}
+		pruned = append(pruned, edge)
+	}
+	return pruned
+}
+
+// callDepths returns the minimum call depth of each function reachable from the
+// roots (i.e. callees with missing caller information) of the given call graph.
+// Roots have depth 0.
+func callDepths(edges []Edge) map[string]int {
+	zero := StackFrame{}
+	callees := make(map[string][]string)
+	depths := make(map[string]int)
+	var queue []string
+	for _, edge := range edges {
+		if edge.Src == zero {
+			if _, ok := depths[edge.Dst.FuncName]; !ok {
+				depths[edge.Dst.FuncName] = 0
+				queue = append(queue, edge.Dst.FuncName)
+			}
+			continue
+		}
+		callees[edge.Src.FuncName] = append(callees[edge.Src.FuncName], edge.Dst.FuncName)
+	}
+	// Breadth-first traversal from the roots.
+	for len(queue) > 0 {
+		caller := queue[0]
+		queue = queue[1:]
+		for _, callee := range callees[caller] {
+			if _, ok := depths[callee]; ok {
+				continue
+			}
+			depths[callee] = depths[caller] + 1
+			queue = append(queue, callee)
+		}
+	}
+	return depths
+}
+
+// CallDepths returns the minimum call depth of each function of the given call
+// graph, as used to annotate the architectural layers of a program. Roots have
+// depth 0, and include both callees with missing caller information and callers
+// which are never called themselves (e.g. "__libc_start_main" or "main" when
+// outside of the backtrace window). Functions only reachable through cycles
+// without a root are omitted.
+func CallDepths(edges []Edge) map[string]int {
+	depths := callDepths(edges)
+	zero := StackFrame{}
+	called := make(map[string]bool)
+	for _, edge := range edges {
+		called[edge.Dst.FuncName] = true
+	}
+	// Treat callers which are never called as additional roots.
+	extra := false
+	for _, edge := range edges {
+		if edge.Src == zero || called[edge.Src.FuncName] {
+			continue
+		}
+		if _, ok := depths[edge.Src.FuncName]; !ok {
+			extra = true
+			break
+		}
+	}
+	if !extra {
+		return depths
+	}
+	var rooted []Edge
+	for _, edge := range edges {
+		if edge.Src != zero && !called[edge.Src.FuncName] {
+			// Root edge of caller.
+			rooted = append(rooted, Edge{Dst: edge.Src})
+		}
+		rooted = append(rooted, edge)
+	}
+	return callDepths(rooted)
+}
+
+// leafFunctions returns the leaf functions of the given call graph (i.e.
+// functions which are called but never observed calling other functions), in
+// order of first appearance.
+func leafFunctions(edges []Edge) []string {
+	zero := StackFrame{}
+	callers := make(map[string]bool)
+	for _, edge := range edges {
+		if edge.Src != zero {
+			callers[edge.Src.FuncName] = true
+		}
+	}
+	var leaves []string
+	for _, name := range newNodeIDs(edges).names {
+		if !callers[name] {
+			leaves = append(leaves, name)
+		}
+	}
+	return leaves
+}
+
+// FindCycles returns the strongly connected components of the given call graph
+// which contain cycles (i.e. recursion), by function name. Each component
+// either contains multiple mutually recursive functions or a single directly
+// recursive function.
+func FindCycles(edges []Edge) [][]string {
+	zero := StackFrame{}
+	callees := make(map[string][]string)
+	selfLoop := make(map[string]bool)
+	ids := newNodeIDs(edges)
+	for _, edge := range edges {
+		if edge.Src == zero {
+			continue
+		}
+		callees[edge.Src.FuncName] = append(callees[edge.Src.FuncName], edge.Dst.FuncName)
+		if edge.Src.FuncName == edge.Dst.FuncName {
+			selfLoop[edge.Src.FuncName] = true
+		}
+	}
+	// Tarjan's strongly connected components algorithm.
+	var (
+		index   = make(map[string]int)
+		lowLink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		cycles  [][]string
+	)
+	var strongConnect func(v string)
+	strongConnect = func(v string) {
+		index[v] = len(index)
+		lowLink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range callees[v] {
+			if _, ok := index[w]; !ok {
+				strongConnect(w)
+				if lowLink[w] < lowLink[v] {
+					lowLink[v] = lowLink[w]
+				}
+			} else if onStack[w] && index[w] < lowLink[v] {
+				lowLink[v] = index[w]
+			}
+		}
+		if lowLink[v] != index[v] {
+			return
+		}
+		// v is the root of a strongly connected component.
+		var scc []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		if len(scc) > 1 || selfLoop[v] {
+			cycles = append(cycles, scc)
+		}
+	}
+	for _, name := range ids.names {
+		if _, ok := index[name]; !ok {
+			strongConnect(name)
+		}
+	}
+	return cycles
+}
+
+// Degree records the in-degree and out-degree of a node in the call graph.
+type Degree struct {
+	// Number of distinct callers (fan-in).
+	In int
+	// Number of distinct callees (fan-out).
+	Out int
+}
+
+// Degrees returns the in-degree and out-degree of each function in the given
+// call graph, keyed by function name. Repeated calls between the same caller
+// and callee are counted once. Return edges (see EdgeReturn) are ignored.
+func Degrees(edges []Edge) map[string]Degree {
+	edges, _ = SplitReturns(edges)
+	zero := StackFrame{}
+	degrees := make(map[string]Degree)
+	for _, edge := range CollapseEdges(edges) {
+		dst := degrees[edge.Dst.FuncName]
+		if edge.Src == zero {
+			// Root node; record presence.
+			degrees[edge.Dst.FuncName] = dst
+			continue
+		}
+		dst.In++
+		degrees[edge.Dst.FuncName] = dst
+		src := degrees[edge.Src.FuncName]
+		src.Out++
+		degrees[edge.Src.FuncName] = src
+	}
+	return degrees
+}
+
+// SplitReturns splits the given edges into call edges and return edges (see
+// EdgeReturn), preserving the order of edges.
+func SplitReturns(edges []Edge) (calls, returns []Edge) {
+	for _, edge := range edges {
+		if edge.Kind == EdgeReturn {
+			returns = append(returns, edge)
+			continue
+		}
+		calls = append(calls, edge)
+	}
+	return calls, returns
+}
+
+// ReturnsWithin returns the return edges between functions of the given call
+// graph (e.g. after focusing the call graph on a subset of functions).
+func ReturnsWithin(returns, edges []Edge) []Edge {
+	frames := FuncFrames(edges)
+	var within []Edge
+	for _, edge := range returns {
+		_, srcOk := frames[edge.Src.FuncName]
+		_, dstOk := frames[edge.Dst.FuncName]
+		if srcOk && dstOk {
+			within = append(within, edge)
+		}
+	}
+	return within
+}
+
+// FuncFrames returns the latest seen callee stack frame of each function in the
+// given call graph, keyed by function name. The source location of a callee
+// stack frame is located within the function itself (as opposed to caller
+// stack frames, which point to the call site). Functions only seen as callers
+// are mapped to their latest seen caller stack frame.
+func FuncFrames(edges []Edge) map[string]StackFrame {
+	zero := StackFrame{}
+	frames := make(map[string]StackFrame)
+	// Functions seen as callee.
+	callees := make(map[string]bool)
+	for _, edge := range edges {
+		if edge.Src != zero && !callees[edge.Src.FuncName] {
+			frames[edge.Src.FuncName] = edge.Src
+		}
+		frames[edge.Dst.FuncName] = edge.Dst
+		callees[edge.Dst.FuncName] = true
+	}
+	return frames
+}
+
+// PruneMinCount prunes the edges of the given call graph whose caller/callee
+// pair was observed fewer than minCount times (see CollapseEdges). Edges with
+// missing caller information (i.e. roots) are pruned if the root is isolated
+// after pruning.
+func PruneMinCount(edges []Edge, minCount int) []Edge {
+	zero := StackFrame{}
+	// Call count of each caller/callee pair.
+	counts := make(map[edgeKey]int)
+	for _, edge := range CollapseEdges(edges) {
+		counts[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}] = edge.Count
+	}
+	var pruned []Edge
+	// Functions of remaining edges.
+	connected := make(map[string]bool)
+	for _, edge := range edges {
+		if edge.Src == zero {
+			continue
+		}
+		if counts[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}] < minCount {
+			continue
+		}
+		connected[edge.Src.FuncName] = true
+		connected[edge.Dst.FuncName] = true
+	}
+	for _, edge := range edges {
+		if edge.Src == zero {
+			if connected[edge.Dst.FuncName] {
+				pruned = append(pruned, edge)
+			}
+			continue
+		}
+		if counts[edgeKey{src: edge.Src.FuncName, dst: edge.Dst.FuncName}] < minCount {
+			continue
+		}
+		pruned = append(pruned, edge)
+	}
+	return pruned
+}
+
+// Reroot returns the edges of the given call graph reparented under the
+// specified root function. Callers outside of the traced functions (i.e.
+// callers never seen as callee, such as "__libc_start_call_main") and missing
+// callers are replaced by the root, and the root is made the only root of the
+// call graph.
+func Reroot(edges []Edge, root string) []Edge {
+	zero := StackFrame{}
+	// Functions seen as callee.
+	callees := make(map[string]bool)
+	for _, edge := range edges {
+		callees[edge.Dst.FuncName] = true
+	}
+	rootFrame := StackFrame{FuncName: root}
+	var rerooted []Edge
+	if !callees[root] {
+		// Root never seen as callee.
+		rerooted = append(rerooted, Edge{Dst: rootFrame})
+	}
+	for _, edge := range edges {
+		orphan := edge.Src == zero || !callees[edge.Src.FuncName]
+		switch {
+		case edge.Dst.FuncName == root:
+			// Root has no caller.
+			edge.Src = zero
+		case orphan:
+			edge.Src = rootFrame
+		}
+		rerooted = append(rerooted, edge)
+	}
+	return rerooted
+}
+
+// MarkMain returns the edges of the given call graph with the specified main
+// function (i.e. the program entry) marked as a root of the call graph, by
+// prepending an edge with missing caller information to the main function. As
+// backtraces are limited in depth, the main function is otherwise not
+// necessarily recorded as a root. The edges are returned as is if the main
+// function is not present in the call graph or already marked as a root.
+func MarkMain(edges []Edge, main string) []Edge {
+	zero := StackFrame{}
+	present := false
+	for _, edge := range edges {
+		if edge.Dst.FuncName == main {
+			if edge.Src == zero {
+				// Main function already a root.
+				return edges
+			}
+			present = true
+		}
+		if edge.Src.FuncName == main {
+			present = true
+		}
+	}
+	if !present {
+		return edges
+	}
+	return append([]Edge{{Dst: StackFrame{FuncName: main}}}, edges...)
+}
+
+// InvertEdges returns the edges of the given call graph inverted, so that each
+// callee points to its caller. Edges with missing caller information (i.e.
+// roots) are kept as is.
+func InvertEdges(edges []Edge) []Edge {
+	zero := StackFrame{}
+	inverted := make([]Edge, 0, len(edges))
+	for _, edge := range edges {
+		if edge.Src != zero {
+			edge.Src, edge.Dst = edge.Dst, edge.Src
+		}
+		inverted = append(inverted, edge)
+	}
+	return inverted
+}
+
+// CallersOf returns the edges of the given call graph leading to the specified
+// function, i.e. the edges between the function and its transitive callers.
+func CallersOf(edges []Edge, funcName string) []Edge {
+	zero := StackFrame{}
+	// Callers of each function, keyed by callee function name.
+	callers := make(map[string][]string)
+	for _, edge := range edges {
+		if edge.Src != zero {
+			callers[edge.Dst.FuncName] = append(callers[edge.Dst.FuncName], edge.Src.FuncName)
+		}
+	}
+	// Breadth-first search from function to its transitive callers.
+	reached := map[string]bool{funcName: true}
+	queue := []string{funcName}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, caller := range callers[name] {
+			if !reached[caller] {
+				reached[caller] = true
+				queue = append(queue, caller)
+			}
+		}
+	}
+	var filtered []Edge
+	for _, edge := range edges {
+		if reached[edge.Dst.FuncName] {
+			filtered = append(filtered, edge)
+		}
+	}
+	return filtered
+}
+
+// Neighborhood returns the edges of the given call graph between functions
+// located within radius hops of the specified function, following edges in
+// either direction.
+func Neighborhood(edges []Edge, funcName string, radius int) []Edge {
+	zero := StackFrame{}
+	// Adjacent functions in either direction, keyed by function name.
+	adj := make(map[string][]string)
+	for _, edge := range edges {
+		if edge.Src != zero {
+			adj[edge.Src.FuncName] = append(adj[edge.Src.FuncName], edge.Dst.FuncName)
+			adj[edge.Dst.FuncName] = append(adj[edge.Dst.FuncName], edge.Src.FuncName)
+		}
+	}
+	// Breadth-first search bounded by radius.
+	dists := map[string]int{funcName: 0}
+	queue := []string{funcName}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if dists[name] >= radius {
+			continue
+		}
+		for _, next := range adj[name] {
+			if _, ok := dists[next]; !ok {
+				dists[next] = dists[name] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	var filtered []Edge
+	for _, edge := range edges {
+		_, dstOk := dists[edge.Dst.FuncName]
+		if edge.Src == zero {
+			if dstOk {
+				filtered = append(filtered, edge)
+			}
+			continue
+		}
+		if _, srcOk := dists[edge.Src.FuncName]; srcOk && dstOk {
+			filtered = append(filtered, edge)
+		}
+	}
+	return filtered
+}
+
+// CalleesOf returns the edges of the given call graph reachable from the
+// specified function, i.e. the edges between the function and its transitive
+// callees.
+func CalleesOf(edges []Edge, funcName string) []Edge {
+	zero := StackFrame{}
+	// Callees of each function, keyed by caller function name.
+	callees := make(map[string][]string)
+	for _, edge := range edges {
+		if edge.Src != zero {
+			callees[edge.Src.FuncName] = append(callees[edge.Src.FuncName], edge.Dst.FuncName)
+		}
+	}
+	// Breadth-first search from function to its transitive callees.
+	reached := map[string]bool{funcName: true}
+	queue := []string{funcName}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, callee := range callees[name] {
+			if !reached[callee] {
+				reached[callee] = true
+				queue = append(queue, callee)
+			}
+		}
+	}
+	var filtered []Edge
+	for _, edge := range edges {
+		if edge.Src == zero {
+			// Keep the function itself if root.
+			if edge.Dst.FuncName == funcName {
+				filtered = append(filtered, edge)
+			}
+			continue
+		}
+		if reached[edge.Src.FuncName] {
+			filtered = append(filtered, edge)
+		}
+	}
+	return filtered
+}
+
+// MergeOverloads merges the overloaded C++ functions of the given call graph
+// by base name (i.e. function name without parameter list; see baseName), and
+// returns the merged edges along with the number of distinct overloads merged
+// into each base name, keyed by base name. Base names of a single overload are
+// omitted from the returned map.
+func MergeOverloads(edges []Edge) ([]Edge, map[string]int) {
+	return mergeFuncNames(edges, baseName)
+}
+
+// CollapseTemplates merges the C++ template instantiations of the given call
+// graph by stripping template arguments from function names (see
+// stripTemplateArgs), and returns the merged edges along with the number of
+// distinct instantiations merged into each function name, keyed by stripped
+// function name. Function names of a single instantiation are omitted from the
+// returned map.
+func CollapseTemplates(edges []Edge) ([]Edge, map[string]int) {
+	return mergeFuncNames(edges, stripTemplateArgs)
+}
+
+// mergeFuncNames merges the functions of the given call graph by normalized
+// function name, and returns the merged edges along with the number of distinct
+// function names merged into each normalized function name, keyed by
+// normalized function name. Normalized function names of a single function are
+// omitted from the returned map.
+func mergeFuncNames(edges []Edge, normalize func(funcName string) string) ([]Edge, map[string]int) {
+	zero := StackFrame{}
+	// Distinct function names of each normalized function name, keyed by
+	// normalized function name.
+	origNames := make(map[string]map[string]bool)
+	merge := func(st *StackFrame) {
+		name := normalize(st.FuncName)
+		if origNames[name] == nil {
+			origNames[name] = make(map[string]bool)
+		}
+		origNames[name][st.FuncName] = true
+		st.FuncName = name
+	}
+	merged := make([]Edge, 0, len(edges))
+	for _, edge := range edges {
+		if edge.Src != zero {
+			merge(&edge.Src)
+		}
+		merge(&edge.Dst)
+		merged = append(merged, edge)
+	}
+	counts := make(map[string]int)
+	for name, names := range origNames {
+		if len(names) > 1 {
+			counts[name] = len(names)
+		}
+	}
+	return merged, counts
+}
+
+// ConnectedComponents returns the weakly connected components of the given
+// call graph, each containing the edges between the functions of the
+// component. Components are ordered by their first edge in the call graph.
+func ConnectedComponents(edges []Edge) [][]Edge {
+	zero := StackFrame{}
+	// Adjacent functions in either direction, keyed by function name.
+	adj := make(map[string][]string)
+	for _, edge := range edges {
+		if edge.Src != zero {
+			adj[edge.Src.FuncName] = append(adj[edge.Src.FuncName], edge.Dst.FuncName)
+			adj[edge.Dst.FuncName] = append(adj[edge.Dst.FuncName], edge.Src.FuncName)
+		}
+	}
+	// Component index of each function, keyed by function name.
+	comps := make(map[string]int)
+	var components [][]Edge
+	for _, edge := range edges {
+		name := edge.Dst.FuncName
+		if _, ok := comps[name]; !ok {
+			// Breadth-first search from unvisited function.
+			comp := len(components)
+			components = append(components, nil)
+			comps[name] = comp
+			queue := []string{name}
+			for len(queue) > 0 {
+				name := queue[0]
+				queue = queue[1:]
+				for _, next := range adj[name] {
+					if _, ok := comps[next]; !ok {
+						comps[next] = comp
+						queue = append(queue, next)
+					}
+				}
+			}
+		}
+		comp := comps[name]
+		components[comp] = append(components[comp], edge)
+	}
+	return components
+}
+
+// DiffGraphs compares the call graph b against the baseline call graph a (e.g.
+// the call graphs of two versions of a program). Edges are compared by caller
+// and callee function name and edge kind. The added edges are the edges of b
+// missing from a, the removed edges are the edges of a missing from b, and the
+// common edges are the edges of b present in a. The order of edges is
+// preserved.
+func DiffGraphs(a, b []Edge) (added, removed, common []Edge) {
+	type key struct {
+		src, dst string
+		kind     EdgeKind
+	}
+	inA := make(map[key]bool)
+	for _, edge := range a {
+		inA[key{src: edge.Src.FuncName, dst: edge.Dst.FuncName, kind: edge.Kind}] = true
+	}
+	inB := make(map[key]bool)
+	for _, edge := range b {
+		k := key{src: edge.Src.FuncName, dst: edge.Dst.FuncName, kind: edge.Kind}
+		inB[k] = true
+		if inA[k] {
+			common = append(common, edge)
+		} else {
+			added = append(added, edge)
+		}
+	}
+	for _, edge := range a {
+		if !inB[key{src: edge.Src.FuncName, dst: edge.Dst.FuncName, kind: edge.Kind}] {
+			removed = append(removed, edge)
+		}
+	}
+	return added, removed, common
+}
+
+// Graph is a call graph with adjacency maps for querying the callers and
+// callees of functions.
+type Graph struct {
+	// Call graph edges.
+	Edges []Edge
+	// Function names in order of first appearance.
+	nodes []string
+	// Unique callers of each function in order of first appearance, keyed by
+	// function name.
+	callers map[string][]string
+	// Unique callees of each function in order of first appearance, keyed by
+	// function name.
+	callees map[string][]string
+}
+
+// NewGraph returns a new call graph of the given edges. Return edges (see
+// EdgeReturn) are retained in Edges, but do not contribute to the callers and
+// callees of functions.
+func NewGraph(edges []Edge) *Graph {
+	calls, _ := SplitReturns(edges)
+	g := &Graph{
+		Edges:   edges,
+		nodes:   newNodeIDs(calls).names,
+		callers: make(map[string][]string),
+		callees: make(map[string][]string),
+	}
+	zero := StackFrame{}
+	for _, edge := range CollapseEdges(calls) {
+		if edge.Src == zero {
+			continue
+		}
+		src, dst := edge.Src.FuncName, edge.Dst.FuncName
+		g.callers[dst] = append(g.callers[dst], src)
+		g.callees[src] = append(g.callees[src], dst)
+	}
+	return g
+}
+
+// Nodes returns the function names of the call graph, in order of first
+// appearance.
+func (g *Graph) Nodes() []string {
+	return g.nodes
+}
+
+// Callers returns the immediate callers of the given function.
+func (g *Graph) Callers(funcName string) []string {
+	return g.callers[funcName]
+}
+
+// Callees returns the immediate callees of the given function.
+func (g *Graph) Callees(funcName string) []string {
+	return g.callees[funcName]
+}
+
+// Reachable returns the functions transitively called by the given function,
+// in breadth-first order. The given function is only included if recursive.
+func (g *Graph) Reachable(funcName string) []string {
+	var reachable []string
+	seen := make(map[string]bool)
+	queue := []string{funcName}
+	for len(queue) > 0 {
+		caller := queue[0]
+		queue = queue[1:]
+		for _, callee := range g.callees[caller] {
+			if seen[callee] {
+				continue
+			}
+			seen[callee] = true
+			reachable = append(reachable, callee)
+			queue = append(queue, callee)
+		}
+	}
+	return reachable
+}
@@ -0,0 +1,472 @@
+package callgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewGraphReturns(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	edges := []Edge{
+		{Dst: main},
+		{Src: main, Dst: foo},
+		{Src: foo, Dst: bar},
+		// Return edges of -returns.
+		{Src: bar, Dst: foo, Kind: EdgeReturn},
+		{Src: foo, Dst: main, Kind: EdgeReturn},
+	}
+	g := NewGraph(edges)
+	if len(g.Edges) != len(edges) {
+		t.Errorf("number of edges mismatch; expected %d, got %d", len(edges), len(g.Edges))
+	}
+	golden := []struct {
+		funcName string
+		callers  []string
+		callees  []string
+	}{
+		{funcName: "main", callers: nil, callees: []string{"foo"}},
+		{funcName: "foo", callers: []string{"main"}, callees: []string{"bar"}},
+		{funcName: "bar", callers: []string{"foo"}, callees: nil},
+	}
+	for _, gold := range golden {
+		if got := g.Callers(gold.funcName); !reflect.DeepEqual(got, gold.callers) {
+			t.Errorf("callers of %q mismatch; expected %q, got %q", gold.funcName, gold.callers, got)
+		}
+		if got := g.Callees(gold.funcName); !reflect.DeepEqual(got, gold.callees) {
+			t.Errorf("callees of %q mismatch; expected %q, got %q", gold.funcName, gold.callees, got)
+		}
+	}
+	want := map[string]Degree{
+		"main": {In: 0, Out: 1},
+		"foo":  {In: 1, Out: 1},
+		"bar":  {In: 1, Out: 0},
+	}
+	if got := Degrees(edges); !reflect.DeepEqual(got, want) {
+		t.Errorf("degrees mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestCollapseEdges(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo", Args: "n=1"}
+	foo2 := StackFrame{FuncName: "foo", Args: "n=2"}
+	bar := StackFrame{FuncName: "bar"}
+	golden := []struct {
+		desc  string
+		edges []Edge
+		want  []CountedEdge
+	}{
+		{
+			desc:  "empty",
+			edges: nil,
+			want:  nil,
+		},
+		{
+			desc: "repeated edge",
+			edges: []Edge{
+				{Src: main, Dst: foo, Index: 0},
+				{Src: main, Dst: foo, Index: 1},
+			},
+			want: []CountedEdge{
+				{Edge: Edge{Src: main, Dst: foo, Index: 0}, Count: 2, Runs: 1},
+			},
+		},
+		{
+			desc: "order of first occurrence",
+			edges: []Edge{
+				{Src: foo, Dst: bar},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: bar},
+			},
+			want: []CountedEdge{
+				{Edge: Edge{Src: foo, Dst: bar}, Count: 2, Runs: 1},
+				{Edge: Edge{Src: main, Dst: foo}, Count: 1, Runs: 1},
+			},
+		},
+		{
+			desc: "differing callee arguments",
+			edges: []Edge{
+				{Src: main, Dst: foo},
+				{Src: main, Dst: foo2},
+			},
+			want: []CountedEdge{
+				{Edge: Edge{Src: main, Dst: foo}, Count: 2, MixedArgs: true, Runs: 1},
+			},
+		},
+		{
+			desc: "distinct runs",
+			edges: []Edge{
+				{Src: main, Dst: foo, Run: 0},
+				{Src: main, Dst: foo, Run: 1},
+				{Src: main, Dst: foo, Run: 1},
+			},
+			want: []CountedEdge{
+				{Edge: Edge{Src: main, Dst: foo, Run: 0}, Count: 3, Runs: 2},
+			},
+		},
+		{
+			desc: "calls and returns kept apart",
+			edges: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: main, Kind: EdgeReturn},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: main, Kind: EdgeReturn},
+			},
+			want: []CountedEdge{
+				{Edge: Edge{Dst: main}, Count: 1, Runs: 1},
+				{Edge: Edge{Src: main, Dst: foo}, Count: 2, Runs: 1},
+				{Edge: Edge{Src: foo, Dst: main, Kind: EdgeReturn}, Count: 2, Runs: 1},
+			},
+		},
+	}
+	for _, g := range golden {
+		got := CollapseEdges(g.edges)
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%s: collapsed edges mismatch; expected %+v, got %+v", g.desc, g.want, got)
+		}
+	}
+}
+
+func TestDegrees(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	baz := StackFrame{FuncName: "baz"}
+	golden := []struct {
+		desc  string
+		edges []Edge
+		want  map[string]Degree
+	}{
+		{
+			desc:  "root edge",
+			edges: []Edge{{Dst: main}},
+			want:  map[string]Degree{"main": {}},
+		},
+		{
+			desc: "repeated calls",
+			edges: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: main, Dst: foo},
+				{Src: main, Dst: bar},
+				{Src: foo, Dst: baz},
+				{Src: bar, Dst: baz},
+			},
+			want: map[string]Degree{
+				"main": {In: 0, Out: 2},
+				"foo":  {In: 1, Out: 1},
+				"bar":  {In: 1, Out: 1},
+				"baz":  {In: 2, Out: 0},
+			},
+		},
+		{
+			// Roots stay roots and leaves stay leaves with returns recorded.
+			desc: "returns",
+			edges: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: main, Kind: EdgeReturn},
+				{Src: main, Dst: bar},
+				{Src: bar, Dst: main, Kind: EdgeReturn},
+			},
+			want: map[string]Degree{
+				"main": {In: 0, Out: 2},
+				"foo":  {In: 1, Out: 0},
+				"bar":  {In: 1, Out: 0},
+			},
+		},
+		{
+			desc: "recursion",
+			edges: []Edge{
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: foo},
+			},
+			want: map[string]Degree{
+				"main": {In: 0, Out: 1},
+				"foo":  {In: 2, Out: 1},
+			},
+		},
+	}
+	for _, g := range golden {
+		if got := Degrees(g.edges); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%s: degrees mismatch; expected %v, got %v", g.desc, g.want, got)
+		}
+	}
+}
+
+func TestDiffGraphs(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	baz := StackFrame{FuncName: "baz"}
+	a := []Edge{
+		{Dst: main},
+		{Src: main, Dst: foo},
+		{Src: foo, Dst: bar},
+		{Src: foo, Dst: bar},
+		{Src: bar, Dst: foo, Kind: EdgeReturn},
+	}
+	b := []Edge{
+		{Dst: main},
+		{Src: main, Dst: foo},
+		{Src: main, Dst: foo},
+		{Src: main, Dst: foo},
+		{Src: foo, Dst: baz},
+		{Src: foo, Dst: baz},
+		// Return edge without its call counterpart in a.
+		{Src: baz, Dst: foo, Kind: EdgeReturn},
+	}
+	added, removed, common := DiffGraphs(a, b)
+	wantAdded := []Edge{
+		{Src: foo, Dst: baz},
+		{Src: foo, Dst: baz},
+		{Src: baz, Dst: foo, Kind: EdgeReturn},
+	}
+	if !reflect.DeepEqual(added, wantAdded) {
+		t.Errorf("added edges mismatch; expected %+v, got %+v", wantAdded, added)
+	}
+	wantRemoved := []Edge{
+		{Src: foo, Dst: bar},
+		{Src: foo, Dst: bar},
+		{Src: bar, Dst: foo, Kind: EdgeReturn},
+	}
+	if !reflect.DeepEqual(removed, wantRemoved) {
+		t.Errorf("removed edges mismatch; expected %+v, got %+v", wantRemoved, removed)
+	}
+	// Common edges retain the occurrences of b, thus reflecting changed call
+	// counts.
+	wantCommon := []CountedEdge{
+		{Edge: Edge{Dst: main}, Count: 1, Runs: 1},
+		{Edge: Edge{Src: main, Dst: foo}, Count: 3, Runs: 1},
+	}
+	if got := CollapseEdges(common); !reflect.DeepEqual(got, wantCommon) {
+		t.Errorf("common edges mismatch; expected %+v, got %+v", wantCommon, got)
+	}
+	// Identical call graphs.
+	added, removed, common = DiffGraphs(a, a)
+	if len(added) != 0 || len(removed) != 0 || len(common) != len(a) {
+		t.Errorf("diff of identical call graphs mismatch; got %d added, %d removed and %d common edges", len(added), len(removed), len(common))
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	baz := StackFrame{FuncName: "baz"}
+	golden := []struct {
+		desc  string
+		edges []Edge
+		want  [][]string
+	}{
+		{
+			desc: "acyclic",
+			edges: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: main, Dst: bar},
+				{Src: foo, Dst: bar},
+			},
+			want: nil,
+		},
+		{
+			desc: "direct recursion",
+			edges: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: foo},
+			},
+			want: [][]string{{"foo"}},
+		},
+		{
+			desc: "mutual recursion",
+			edges: []Edge{
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: bar},
+				{Src: bar, Dst: baz},
+				{Src: baz, Dst: foo},
+				{Src: main, Dst: main},
+			},
+			want: [][]string{{"baz", "bar", "foo"}, {"main"}},
+		},
+	}
+	for _, g := range golden {
+		if got := FindCycles(g.edges); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%s: cycles mismatch; expected %q, got %q", g.desc, g.want, got)
+		}
+	}
+}
+
+func TestCallDepths(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	baz := StackFrame{FuncName: "baz"}
+	golden := []struct {
+		desc  string
+		edges []Edge
+		want  map[string]int
+	}{
+		{
+			desc: "minimum depth",
+			edges: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: bar},
+				{Src: bar, Dst: baz},
+				{Src: main, Dst: baz},
+			},
+			want: map[string]int{"main": 0, "foo": 1, "bar": 2, "baz": 1},
+		},
+		{
+			// Caller outside of the backtrace window is never called.
+			desc: "uncalled caller",
+			edges: []Edge{
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: bar},
+			},
+			want: map[string]int{"main": 0, "foo": 1, "bar": 2},
+		},
+		{
+			desc: "recursion",
+			edges: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: foo},
+				{Src: foo, Dst: bar},
+			},
+			want: map[string]int{"main": 0, "foo": 1, "bar": 2},
+		},
+		{
+			desc: "cycle without root",
+			edges: []Edge{
+				{Dst: main},
+				{Src: foo, Dst: bar},
+				{Src: bar, Dst: foo},
+			},
+			want: map[string]int{"main": 0},
+		},
+	}
+	for _, g := range golden {
+		if got := CallDepths(g.edges); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%s: call depths mismatch; expected %v, got %v", g.desc, g.want, got)
+		}
+	}
+}
+
+func TestPruneMinCount(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	baz := StackFrame{FuncName: "baz"}
+	edges := []Edge{
+		{Dst: main},
+		{Src: main, Dst: foo},
+		{Src: main, Dst: foo},
+		{Src: foo, Dst: bar},
+		{Src: foo, Dst: bar},
+		{Src: foo, Dst: bar},
+		{Src: main, Dst: baz},
+		// Isolated root.
+		{Dst: baz},
+	}
+	golden := []struct {
+		minCount int
+		want     []Edge
+	}{
+		{minCount: 0, want: edges},
+		{minCount: 1, want: edges},
+		{
+			minCount: 2,
+			want: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: bar},
+				{Src: foo, Dst: bar},
+				{Src: foo, Dst: bar},
+			},
+		},
+		{
+			minCount: 3,
+			want: []Edge{
+				{Src: foo, Dst: bar},
+				{Src: foo, Dst: bar},
+				{Src: foo, Dst: bar},
+			},
+		},
+		{minCount: 4, want: nil},
+	}
+	for _, g := range golden {
+		if got := PruneMinCount(edges, g.minCount); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("min count %d: pruned edges mismatch; expected %+v, got %+v", g.minCount, g.want, got)
+		}
+	}
+}
+
+func TestReroot(t *testing.T) {
+	start := StackFrame{FuncName: "__libc_start_call_main"}
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	handler := StackFrame{FuncName: "handler"}
+	root := StackFrame{FuncName: "main"}
+	golden := []struct {
+		desc  string
+		edges []Edge
+		root  string
+		want  []Edge
+	}{
+		{
+			desc: "untraced caller",
+			edges: []Edge{
+				{Src: start, Dst: main},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: bar},
+			},
+			root: "main",
+			want: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: foo, Dst: bar},
+			},
+		},
+		{
+			desc: "missing callers",
+			edges: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Dst: handler},
+				{Src: handler, Dst: bar},
+			},
+			root: "main",
+			want: []Edge{
+				{Dst: main},
+				{Src: main, Dst: foo},
+				{Src: root, Dst: handler},
+				{Src: handler, Dst: bar},
+			},
+		},
+		{
+			desc: "root never seen as callee",
+			edges: []Edge{
+				{Src: foo, Dst: bar},
+				{Dst: handler},
+			},
+			root: "app",
+			want: []Edge{
+				{Dst: StackFrame{FuncName: "app"}},
+				{Src: StackFrame{FuncName: "app"}, Dst: bar},
+				{Src: StackFrame{FuncName: "app"}, Dst: handler},
+			},
+		},
+	}
+	for _, g := range golden {
+		if got := Reroot(g.edges, g.root); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%s: rerooted edges mismatch; expected %+v, got %+v", g.desc, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package callgraph
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// WriteGraphML writes the given call graph to w in GraphML format, for import
+// into yEd, Gephi and other graph editors. Repeated calls between the same
+// caller and callee are collapsed into a single edge.
+//
+// Example output:
+//
+//    <graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+//       <key id="label" for="node" attr.name="label" attr.type="string"></key>
+//       <key id="args" for="edge" attr.name="args" attr.type="string"></key>
+//       <graph id="callgraph" edgedefault="directed">
+//          <node id="n0">
+//             <data key="label">main</data>
+//          </node>
+//          <node id="n1">
+//             <data key="label">foo</data>
+//          </node>
+//          <edge source="n0" target="n1">
+//             <data key="args">n=23</data>
+//          </edge>
+//       </graph>
+//    </graphml>
+func WriteGraphML(w io.Writer, edges []Edge) error {
+	ids := newNodeIDs(edges)
+	g := graphmlGraph{
+		ID:          "callgraph",
+		EdgeDefault: "directed",
+	}
+	for _, name := range ids.names {
+		node := graphmlNode{
+			ID:   ids.id(name),
+			Data: []graphmlData{{Key: "label", Value: name}},
+		}
+		g.Nodes = append(g.Nodes, node)
+	}
+	zero := StackFrame{}
+	for _, edge := range CollapseEdges(edges) {
+		if edge.Src == zero {
+			// Caller information missing; node already declared.
+			continue
+		}
+		e := graphmlEdge{
+			Source: ids.id(edge.Src.FuncName),
+			Target: ids.id(edge.Dst.FuncName),
+		}
+		if len(edge.Dst.Args) > 0 {
+			e.Data = append(e.Data, graphmlData{Key: "args", Value: edge.Dst.Args})
+		}
+		g.Edges = append(g.Edges, e)
+	}
+	doc := graphmlDoc{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphmlKey{
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "args", For: "edge", AttrName: "args", AttrType: "string"},
+		},
+		Graph: g,
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return errors.WithStack(err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// graphmlDoc is a GraphML document.
+type graphmlDoc struct {
+	XMLName xml.Name `xml:"graphml"`
+	// GraphML namespace.
+	Xmlns string `xml:"xmlns,attr"`
+	// Declarations of custom data fields.
+	Keys []graphmlKey `xml:"key"`
+	// Call graph.
+	Graph graphmlGraph `xml:"graph"`
+}
+
+// graphmlKey declares a custom GraphML data field.
+type graphmlKey struct {
+	// Data field ID.
+	ID string `xml:"id,attr"`
+	// Element type of data field (node or edge).
+	For string `xml:"for,attr"`
+	// Data field name.
+	AttrName string `xml:"attr.name,attr"`
+	// Data field type.
+	AttrType string `xml:"attr.type,attr"`
+}
+
+// graphmlGraph is a GraphML graph.
+type graphmlGraph struct {
+	// Graph ID.
+	ID string `xml:"id,attr"`
+	// Default edge direction.
+	EdgeDefault string `xml:"edgedefault,attr"`
+	// Graph nodes.
+	Nodes []graphmlNode `xml:"node"`
+	// Graph edges.
+	Edges []graphmlEdge `xml:"edge"`
+}
+
+// graphmlNode is a GraphML node.
+type graphmlNode struct {
+	// Node ID.
+	ID string `xml:"id,attr"`
+	// Node data fields.
+	Data []graphmlData `xml:"data"`
+}
+
+// graphmlEdge is a GraphML edge.
+type graphmlEdge struct {
+	// Source node ID.
+	Source string `xml:"source,attr"`
+	// Target node ID.
+	Target string `xml:"target,attr"`
+	// Edge data fields.
+	Data []graphmlData `xml:"data"`
+}
+
+// graphmlData is a GraphML data field value.
+type graphmlData struct {
+	// Data field ID.
+	Key string `xml:"key,attr"`
+	// Data field value.
+	Value string `xml:",chardata"`
+}
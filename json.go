@@ -0,0 +1,164 @@
+package callgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// jsonEdge is the JSON representation of a call graph edge.
+type jsonEdge struct {
+	// Caller function; nil if caller information is missing (i.e. root).
+	Src *jsonFrame `json:"src"`
+	// Callee function.
+	Dst *jsonFrame `json:"dst"`
+	// Arguments of callee function.
+	Args string `json:"args"`
+	// Source code of callee source line.
+	SrcLine string `json:"srcLine"`
+	// Kind of edge; omitted for calls, "return" for return edges.
+	Kind string `json:"kind,omitempty"`
+}
+
+// jsonFrame is the JSON representation of a stack frame.
+type jsonFrame struct {
+	// Function name.
+	FuncName string `json:"funcName"`
+	// Function arguments.
+	Args string `json:"args"`
+	// Source file name at function call site.
+	SrcFile string `json:"srcFile,omitempty"`
+	// Line number at function call site.
+	LineNum int `json:"lineNum,omitempty"`
+	// Program counter at the call site (i.e. return address) of caller stack
+	// frames, in hexadecimal (e.g. "0x555555555152"); omitted if not present.
+	CallSitePC string `json:"callSitePC,omitempty"`
+}
+
+// newJSONFrame returns the JSON representation of the given stack frame.
+func newJSONFrame(st StackFrame) *jsonFrame {
+	frame := &jsonFrame{
+		FuncName: st.FuncName,
+		Args:     st.Args,
+		SrcFile:  st.SrcFile,
+		LineNum:  st.LineNum,
+	}
+	if st.CallSitePC != 0 {
+		frame.CallSitePC = fmt.Sprintf("0x%x", st.CallSitePC)
+	}
+	return frame
+}
+
+// stackFrame returns the stack frame of the given JSON representation.
+func (frame *jsonFrame) stackFrame() (StackFrame, error) {
+	st := StackFrame{
+		FuncName: frame.FuncName,
+		Args:     frame.Args,
+		SrcFile:  frame.SrcFile,
+		LineNum:  frame.LineNum,
+	}
+	if len(frame.CallSitePC) > 0 {
+		pc, err := strconv.ParseUint(frame.CallSitePC, 0, 64)
+		if err != nil {
+			return StackFrame{}, errors.Wrapf(err, "unable to parse call site address %q of function %q", frame.CallSitePC, frame.FuncName)
+		}
+		st.CallSitePC = pc
+	}
+	return st, nil
+}
+
+// newJSONEdge returns the JSON representation of the given edge.
+func newJSONEdge(edge Edge) jsonEdge {
+	e := jsonEdge{
+		Dst:     newJSONFrame(edge.Dst),
+		Args:    edge.Dst.Args,
+		SrcLine: edge.SrcLine,
+	}
+	if edge.Src != (StackFrame{}) {
+		e.Src = newJSONFrame(edge.Src)
+	}
+	if edge.Kind != EdgeCall {
+		e.Kind = edge.Kind.String()
+	}
+	return e
+}
+
+// WriteJSONLine writes the given edge to w as a single line of JSON (i.e. JSON
+// Lines format), using the edge representation of WriteJSON. Each line is
+// written using a single write, so that streamed edges are never interleaved.
+//
+// Example output:
+//
+//    {"src":{"funcName":"foo","args":"n=23","srcFile":"test.c","lineNum":19,"callSitePC":"0x555555555171"},"dst":{"funcName":"bar","args":"n=23","srcFile":"test.c","lineNum":25},"args":"n=23","srcLine":"25\t  baz(n);"}
+func WriteJSONLine(w io.Writer, edge Edge) error {
+	buf, err := json.Marshal(newJSONEdge(edge))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf = append(buf, '\n')
+	if _, err := w.Write(buf); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// WriteJSON writes the given call graph to w in JSON format, as an array of
+// edges. Edges with missing caller information (i.e. roots) are represented
+// with a null "src" field. The call site address of callers is included as
+// hexadecimal "callSitePC" field, if present (e.g. to cross-reference edges
+// with a disassembly).
+func WriteJSON(w io.Writer, edges []Edge) error {
+	jsonEdges := make([]jsonEdge, 0, len(edges))
+	for _, edge := range edges {
+		jsonEdges = append(jsonEdges, newJSONEdge(edge))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(jsonEdges); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// ReadJSON reads a call graph in JSON format from r, as written by WriteJSON
+// (e.g. to compare a saved call graph with a new trace).
+func ReadJSON(r io.Reader) ([]Edge, error) {
+	var jsonEdges []jsonEdge
+	if err := json.NewDecoder(r).Decode(&jsonEdges); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	edges := make([]Edge, 0, len(jsonEdges))
+	for i, e := range jsonEdges {
+		if e.Dst == nil {
+			return nil, errors.Errorf("missing callee of edge %d", i)
+		}
+		edge := Edge{
+			SrcLine: e.SrcLine,
+		}
+		switch e.Kind {
+		case "":
+			edge.Kind = EdgeCall
+		case EdgeReturn.String():
+			edge.Kind = EdgeReturn
+		default:
+			return nil, errors.Errorf("invalid kind %q of edge %d", e.Kind, i)
+		}
+		if e.Src != nil {
+			src, err := e.Src.stackFrame()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			edge.Src = src
+		}
+		dst, err := e.Dst.stackFrame()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		edge.Dst = dst
+		edges = append(edges, edge)
+	}
+	return edges, nil
+}
@@ -0,0 +1,530 @@
+package callgraph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LLDB is a Debugger which traces call graphs of binary executables using
+// LLDB.
+type LLDB struct {
+	// Path to LLDB executable.
+	Path string
+	// Tracing options.
+	Options
+}
+
+// NewLLDB returns a new LLDB tracer with default settings.
+func NewLLDB() *LLDB {
+	return &LLDB{
+		Path:    "lldb",
+		Options: DefaultOptions(),
+	}
+}
+
+// LLDB command to retrieve debug information of functions.
+//
+// Example LLDB output:
+//
+//    (lldb) image lookup -r -F .
+//    4 matches found in /home/u/test:
+//            Address: test[0x0000000000001139] (test.PT_LOAD[1]..text + 249)
+//            Summary: test`main at test.c:9
+//            Address: test[0x0000000000001160] (test.PT_LOAD[1]..text + 288)
+//            Summary: test`foo at test.c:17
+const lldbGetFuncs = `
+image lookup -r -F .
+`
+
+// Funcs retrieves debug information about functions of the given binary
+// executable.
+func (l *LLDB) Funcs(binPath string) ([]Func, error) {
+	output, err := l.run(binPath, lldbGetFuncs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fns, err := l.ParseFuncs(output)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fns, nil
+}
+
+// lldbFuncRegexp matches the function summary lines of functions with debug
+// information in LLDB output.
+//
+// Example:
+//
+//    Summary: test`main at test.c:9
+//    Summary: test`foo(int) at test.cpp:17:3
+var lldbFuncRegexp = regexp.MustCompile("Summary: [^`]*`(.+?) at (.+?):([0-9]+)(:[0-9]+)?$")
+
+// lldbNonDebugFuncRegexp matches the function summary lines of non-debugging
+// symbols in LLDB output.
+//
+// Example:
+//
+//    Summary: libc.so.6`__libc_start_main
+var lldbNonDebugFuncRegexp = regexp.MustCompile("Summary: [^`]*`([^ ]+)$")
+
+// ParseFuncs parses debug information about functions of the given
+// pre-captured LLDB output, as produced by the LLDB command script of Funcs
+// (see lldbGetFuncs).
+func (l *LLDB) ParseFuncs(output string) ([]Func, error) {
+	var fns []Func
+	var nonDebugFns []Func
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		matches := lldbFuncRegexp.FindStringSubmatch(line)
+		if len(matches) == 0 {
+			if matches := lldbNonDebugFuncRegexp.FindStringSubmatch(line); len(matches) > 0 {
+				sym := l.demangleName(matches[1])
+				fn := Func{
+					Name:     sym,
+					Sig:      sym,
+					NonDebug: true,
+				}
+				nonDebugFns = append(nonDebugFns, fn)
+			}
+			// Skip other output.
+			continue
+		}
+		lineNum, err := strconv.Atoi(matches[3])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		sig := l.demangleName(matches[1])
+		fn := Func{
+			Name: funcNameFromSig(sig),
+			File: l.srcPath(matches[2]),
+			Line: lineNum,
+			Sig:  sig,
+		}
+		fns = append(fns, fn)
+	}
+	if len(fns) == 0 {
+		return nil, errors.Errorf("unable to locate any debug information of functions in LLDB output %q", output)
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		a := fns[i]
+		b := fns[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	// Non-debugging symbols are listed after functions with debug information.
+	fns = append(fns, nonDebugFns...)
+	return fns, nil
+}
+
+// Trace traces the call graph of the specified functions in the given binary
+// and returns the edges of the call graph.
+func (l *LLDB) Trace(binPath string, fns []Func) ([]Edge, error) {
+	if l.PID != 0 {
+		return nil, errors.Errorf("support for attaching to running process not yet implemented for LLDB")
+	}
+	if len(l.Core) > 0 {
+		return nil, errors.Errorf("support for core dump analysis not yet implemented for LLDB")
+	}
+	if l.Returns {
+		return nil, errors.Errorf("support for recording returns not yet implemented for LLDB")
+	}
+	if l.HitsPerFunc > 0 {
+		return nil, errors.Errorf("support for limiting hits per breakpoint not yet implemented for LLDB")
+	}
+	script := l.traceScript(fns)
+	output, err := l.run(binPath, script)
+	if err != nil {
+		if IsPartial(err) {
+			// Parse partial output of hung or failed trace.
+			edges, perr := l.ParseTrace(output, fns)
+			if perr != nil {
+				return nil, errors.WithStack(perr)
+			}
+			l.emitEdges(edges)
+			return edges, err
+		}
+		return nil, errors.WithStack(err)
+	}
+	edges, err := l.ParseTrace(output, fns)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	l.emitEdges(edges)
+	return edges, nil
+}
+
+// emitEdges passes the given edges to l.OnEdge, if set. As LLDB output is parsed
+// once the trace completes, edges are not streamed during tracing.
+func (l *LLDB) emitEdges(edges []Edge) {
+	if l.OnEdge == nil {
+		return
+	}
+	for _, edge := range edges {
+		l.OnEdge(edge)
+	}
+}
+
+// backtraceLen returns the number of stack frames to include in the backtrace
+// of each breakpoint. LLDB gives no indication of truncated backtraces, so to
+// determine whether a callee is located deeper than MaxDepth levels from the
+// root, the backtrace window is widened to at least MaxDepth+2 frames.
+func (l *LLDB) backtraceLen() int {
+	n := l.backtraceWindow()
+	if l.MaxDepth >= 0 && l.MaxDepth+2 > n {
+		n = l.MaxDepth + 2
+	}
+	return n
+}
+
+// FuncsScript returns the LLDB command script used to retrieve debug
+// information about functions (see Funcs).
+func (l *LLDB) FuncsScript() string {
+	return lldbGetFuncs
+}
+
+// TraceScript returns the LLDB command script used to trace the call graph of
+// the specified functions (see Trace).
+func (l *LLDB) TraceScript(fns []Func) string {
+	return l.traceScript(fns)
+}
+
+// traceScript returns the LLDB command script used to trace the call graph of
+// the specified functions.
+func (l *LLDB) traceScript(fns []Func) string {
+	input := &bytes.Buffer{}
+	n := l.backtraceLen()
+	// Breakpoints may only be added to functions with debug information.
+	fns = DebugFuncs(fns)
+	// Add breakpoints, each printing a backtrace before automatically
+	// continuing execution.
+	for _, fn := range fns {
+		cond := ""
+		if c, ok := l.Conditions[funcNameFromSig(fn.Sig)]; ok {
+			cond = fmt.Sprintf(" --condition %q", c)
+		}
+		fmt.Fprintf(input, "breakpoint set --file %q --line %d%s --auto-continue true --command \"thread backtrace -c %d\"\n", fn.File, fn.Line, cond, n)
+	}
+	fmt.Fprintf(input, "%s\n", l.runCommand())
+	return input.String()
+}
+
+// runCommand returns the LLDB command used to launch the traced program, with
+// command line arguments, working directory and standard input redirection.
+//
+// Example:
+//
+//    process launch --working-dir "/srv/game" --environment "FEATURE_X=1" --stdin "input.txt" -- '--headless' 'level1.map'
+func (l *LLDB) runCommand() string {
+	cmd := "process launch"
+	if len(l.WorkingDir) > 0 {
+		cmd += fmt.Sprintf(" --working-dir %q", l.WorkingDir)
+	}
+	for _, env := range l.Env {
+		cmd += fmt.Sprintf(" --environment %q", env)
+	}
+	if len(l.Stdin) > 0 {
+		cmd += fmt.Sprintf(" --stdin %q", l.Stdin)
+	}
+	if len(l.Args) > 0 {
+		cmd += " -- " + shellQuote(l.Args)
+	}
+	return cmd
+}
+
+// run runs LLDB in batch mode on the given binary executable, feeding it the
+// specified command script, and returns the output of LLDB. If LLDB is killed
+// for exceeding the tracing timeout, or exits with a non-zero exit status, the
+// output captured so far is returned alongside ErrTimeout or ErrExitStatus
+// respectively.
+func (l *LLDB) run(binPath, script string) (string, error) {
+	f, err := ioutil.TempFile("", "callgraph_lldb_*.txt")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(script); err != nil {
+		f.Close()
+		return "", errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	output := &bytes.Buffer{}
+	errbuf := &bytes.Buffer{}
+	ctx, cancel := l.newContext()
+	defer cancel()
+	cmd := exec.CommandContext(ctx, l.Path, "--batch", "--no-lldbinit", "--source", f.Name(), "--", binPath)
+	cmd.Stdout = output
+	cmd.Stderr = errbuf
+	if l.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(errbuf, l.Stderr)
+	}
+	if err := runProcessGroup(ctx, cmd, 0); err != nil {
+		if errors.Cause(err) == ErrTimeout {
+			return output.String(), errors.Wrapf(err, "LLDB killed after %v", l.Timeout)
+		}
+		if errors.Cause(err) == ErrExitStatus {
+			return output.String(), errors.Wrapf(err, "LLDB error: %v", errbuf)
+		}
+		return "", errors.Wrapf(err, "LLDB error: %v", errbuf)
+	}
+	return output.String(), nil
+}
+
+// ParseTrace parses the call graph edges of the given pre-captured LLDB trace
+// output, as produced by the LLDB command script of Trace.
+//
+// Example LLDB output:
+//
+//    * thread #1, name = 'test', stop reason = breakpoint 1.1
+//      * frame #0: 0x0000555555555139 test`main(argc=1, argv=0x00007fffffffe6a8) at test.c:11:2
+//    * thread #1, name = 'test', stop reason = breakpoint 2.1
+//      * frame #0: 0x0000555555555160 test`foo(n=23) at test.c:19:2
+//        frame #1: 0x0000555555555152 test`main(argc=1, argv=0x00007fffffffe6a8) at test.c:11:2
+func (l *LLDB) ParseTrace(output string, fns []Func) ([]Edge, error) {
+	const breakpointMarker = "stop reason = breakpoint "
+	var edges []Edge
+	var sts []StackFrame
+	inBacktrace := false
+	// Index of the current breakpoint hit.
+	index := -1
+	// Breakpoint number of the current breakpoint hit.
+	bpNum := 0
+	// Thread number of the current breakpoint hit.
+	thread := 0
+	// flush records the call graph edge of the current backtrace.
+	flush := func() {
+		if !inBacktrace {
+			return
+		}
+		inBacktrace = false
+		if len(sts) == 0 {
+			return
+		}
+		if l.MaxDepth >= 0 && len(sts) > l.MaxDepth+1 {
+			// Callee is located deeper than MaxDepth levels from the root.
+			sts = nil
+			return
+		}
+		// Only stack frames within the backtrace window are of interest; the
+		// remaining stack frames were only used to determine call depth.
+		if len(sts) > l.backtraceWindow() {
+			sts = sts[:l.backtraceWindow()]
+		}
+		for i := range sts {
+			sts[i].Thread = thread
+		}
+		for _, edge := range backtraceEdges(sts) {
+			edge.Index = index
+			edge.Breakpoint = bpNum
+			if l.keepEdge(edge) {
+				edges = append(edges, edge)
+			}
+		}
+		sts = nil
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, breakpointMarker) {
+			flush()
+			inBacktrace = true
+			index++
+			// Example:
+			//
+			//    stop reason = breakpoint 2.1
+			bpNum = 0
+			rawBpNum := line[strings.Index(line, breakpointMarker)+len(breakpointMarker):]
+			if pos := strings.Index(rawBpNum, "."); pos != -1 {
+				rawBpNum = rawBpNum[:pos]
+			}
+			if n, err := strconv.Atoi(rawBpNum); err == nil {
+				bpNum = n
+			}
+			// Example:
+			//
+			//    * thread #2, name = 'test', stop reason = breakpoint 2.1
+			thread = 0
+			if pos := strings.Index(line, "thread #"); pos != -1 {
+				rawThread := line[pos+len("thread #"):]
+				if pos := strings.IndexAny(rawThread, ", "); pos != -1 {
+					rawThread = rawThread[:pos]
+				}
+				if n, err := strconv.Atoi(rawThread); err == nil {
+					thread = n
+				}
+			}
+			continue
+		}
+		if !inBacktrace {
+			continue
+		}
+		st, ok, err := parseLLDBStackFrame(line)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !ok {
+			flush()
+			continue
+		}
+		st.FuncName = l.demangleName(st.FuncName)
+		st.SrcFile = l.srcPath(st.SrcFile)
+		sts = append(sts, st)
+	}
+	flush()
+	if l.MaxDepth >= 0 {
+		edges = pruneDepth(edges, l.MaxDepth)
+	}
+	return edges, nil
+}
+
+// lldbStackFrameRegexp matches LLDB stack frame lines, capturing the stack
+// frame number, program counter, and function name with arguments and optional
+// source location (see parseLLDBStackFrame).
+var lldbStackFrameRegexp = regexp.MustCompile("^[ \t*]*frame #([0-9]+): 0x([0-9A-Fa-f]+)(?: [^`]*`(.+))?$")
+
+// lldbSrcLocRegexp matches the source location of LLDB stack frames, capturing
+// the source file and line number (e.g. "test.c:19:2").
+var lldbSrcLocRegexp = regexp.MustCompile(`^(.+?):([0-9]+)(?::[0-9]+)?$`)
+
+// parseLLDBStackFrame parses the given LLDB stack frame line. The boolean return
+// value indicates whether the line contained a stack frame.
+//
+// Example stack frame lines:
+//
+//    "  * frame #0: 0x0000555555555160 test`foo(n=23) at test.c:19:2"
+//    "    frame #1: 0x0000555555555152 test`main(argc=1, argv=0x00007fffffffe6a8) at test.c:11:2"
+//    "    frame #1: 0x56598d16 test`CCritSect::CCritSect(this=0x5686a728) at storm.h:2079"
+//    "  * frame #0: 0x0000555555555139 test`(anonymous namespace)::helper(x=1) at anon.cc:3:2"
+//    "  * frame #0: 0x0000555555555139 test`main::$_0::operator()(this=0x00007fffffffe0a7, x=1) const at lambda.cc:9:3"
+//    "    frame #2: 0x00007ffff7de70b3 libc.so.6`__libc_start_main + 243"
+//    "    frame #1: 0x00007ffff7a52000"
+func parseLLDBStackFrame(line string) (StackFrame, bool, error) {
+	matches := lldbStackFrameRegexp.FindStringSubmatch(line)
+	if len(matches) == 0 {
+		return StackFrame{}, false, nil
+	}
+	stackFrameNum, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return StackFrame{}, false, errors.WithStack(err)
+	}
+	st := StackFrame{
+		StackFrameNum: stackFrameNum,
+	}
+	if stackFrameNum > 0 {
+		// Return address of caller stack frame.
+		pc, err := strconv.ParseUint(matches[2], 16, 64)
+		if err != nil {
+			return StackFrame{}, false, errors.WithStack(err)
+		}
+		st.CallSitePC = pc
+	}
+	s := matches[3]
+	if len(s) == 0 {
+		// Address-only frame (e.g. stripped or JIT code).
+		st.FuncName = UnknownFuncName
+		st.Unknown = true
+		return st, true, nil
+	}
+	// Function name, preceded by the function it was inlined into for inlined
+	// functions.
+	//
+	// Example:
+	//
+	//    "foo [inlined] bar(n=23)"
+	funcName, s, ok := scanLLDBFuncName(s)
+	const inlinedPrefix = " [inlined] "
+	for ok && strings.HasPrefix(s, inlinedPrefix) {
+		funcName, s, ok = scanLLDBFuncName(s[len(inlinedPrefix):])
+		st.Inlined = true
+	}
+	if !ok {
+		return StackFrame{}, false, errors.Errorf("unable to parse stack frame line %q; invalid function name", line)
+	}
+	st.FuncName = funcName
+	// Optional function arguments.
+	//
+	//    "(n=23)"
+	if strings.HasPrefix(s, "(") {
+		argsEnd := scanBalanced(s)
+		if argsEnd == -1 {
+			return StackFrame{}, false, errors.Errorf("unable to parse stack frame line %q; unbalanced function arguments", line)
+		}
+		st.Args = s[len("(") : argsEnd-len(")")]
+		s = s[argsEnd:]
+	}
+	// Optional source location, following qualifiers of member functions (e.g.
+	// " const"); or PC offset of non-debug frames (e.g. " + 243").
+	//
+	//    " at test.c:19:2"
+	const atSep = " at "
+	if pos := strings.Index(s, atSep); pos != -1 {
+		loc := lldbSrcLocRegexp.FindStringSubmatch(s[pos+len(atSep):])
+		if len(loc) == 0 {
+			return StackFrame{}, false, errors.Errorf("unable to parse stack frame line %q; invalid source location", line)
+		}
+		lineNum, err := strconv.Atoi(loc[2])
+		if err != nil {
+			return StackFrame{}, false, errors.WithStack(err)
+		}
+		st.SrcFile = loc[1]
+		st.LineNum = lineNum
+	}
+	return st, true, nil
+}
+
+// scanLLDBFuncName scans the function name at the start of the given LLDB stack
+// frame suffix (e.g. "foo(n=23) at test.c:19:2"), and returns the function name
+// and the remaining suffix starting at the argument list (e.g.
+// "(n=23) at test.c:19:2"), or at the first space following the function name
+// of frames without arguments. As with scanFuncName, nested template arguments
+// and parentheses of enclosing scopes are skipped (e.g.
+// "(anonymous namespace)::helper" or "main::$_0::operator()"). The boolean
+// return value indicates success.
+func scanLLDBFuncName(s string) (string, string, bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(':
+			if depth == 0 {
+				end := scanBalanced(s[i:])
+				if end == -1 {
+					return "", "", false
+				}
+				if !strings.HasPrefix(s[i+end:], "::") {
+					// Argument list.
+					return s[:i], s[i:], i > 0
+				}
+				// Parenthesized scope (e.g. "(anonymous namespace)::" or
+				// "foo()::").
+				i += end - 1
+				continue
+			}
+			depth++
+		case '<', '[', '{':
+			depth++
+		case '>', ')', ']', '}':
+			depth--
+		case ' ':
+			if depth == 0 {
+				return s[:i], s[i:], i > 0
+			}
+		case 'r':
+			// Skip operator symbols (e.g. "operator<<" or "operator()").
+			if isOperatorKeyword(s[:i+1]) {
+				i += len(operatorSymbol(s[i+1:]))
+			}
+		}
+	}
+	return s, "", len(s) > 0
+}
@@ -0,0 +1,180 @@
+package callgraph
+
+import "testing"
+
+func TestParseLLDBStackFrame(t *testing.T) {
+	golden := []struct {
+		line string
+		want StackFrame
+	}{
+		// Callee stack frame.
+		{
+			line: "  * frame #0: 0x0000555555555160 test`foo(n=23) at test.c:19:2",
+			want: StackFrame{StackFrameNum: 0, FuncName: "foo", Args: "n=23", SrcFile: "test.c", LineNum: 19},
+		},
+		// Caller stack frame with call site address.
+		{
+			line: "    frame #1: 0x0000555555555152 test`main(argc=1, argv=0x00007fffffffe6a8) at test.c:11:2",
+			want: StackFrame{StackFrameNum: 1, FuncName: "main", Args: "argc=1, argv=0x00007fffffffe6a8", SrcFile: "test.c", LineNum: 11, CallSitePC: 0x555555555152},
+		},
+		// Inlined callee of -O2 build, where baz was inlined into bar.
+		{
+			line: "  * frame #0: 0x0000555555555175 test`bar [inlined] baz(n=23) at test.c:31:2",
+			want: StackFrame{StackFrameNum: 0, FuncName: "baz", Args: "n=23", SrcFile: "test.c", LineNum: 31, Inlined: true},
+		},
+		{
+			line: "    frame #1: 0x0000555555555175 test`bar(n=23) at test.c:25:3",
+			want: StackFrame{StackFrameNum: 1, FuncName: "bar", Args: "n=23", SrcFile: "test.c", LineNum: 25, CallSitePC: 0x555555555175},
+		},
+		// Inlined caller of -O2 build, where bar was inlined into foo.
+		{
+			line: "    frame #1: 0x0000555555555189 test`foo [inlined] bar(n=23) at test.c:25:3",
+			want: StackFrame{StackFrameNum: 1, FuncName: "bar", Args: "n=23", SrcFile: "test.c", LineNum: 25, CallSitePC: 0x555555555189, Inlined: true},
+		},
+		// Function in anonymous namespace.
+		{
+			line: "  * frame #0: 0x0000555555555139 test`(anonymous namespace)::helper(x=1) at anon.cc:3:2",
+			want: StackFrame{StackFrameNum: 0, FuncName: "(anonymous namespace)::helper", Args: "x=1", SrcFile: "anon.cc", LineNum: 3},
+		},
+		// Lambda of const member function.
+		{
+			line: "    frame #1: 0x0000555555555171 test`foo()::$_0::operator()(this=0x00007fffffffe0a7, x=1) const at lambda.cc:9:3",
+			want: StackFrame{StackFrameNum: 1, FuncName: "foo()::$_0::operator()", Args: "this=0x00007fffffffe0a7, x=1", SrcFile: "lambda.cc", LineNum: 9, CallSitePC: 0x555555555171},
+		},
+		// Operator functions.
+		{
+			line: "  * frame #0: 0x0000555555555139 test`ns::operator<<(os=0x00007ffff7e1e5c0, v=0x00007fffffffe0a0) at ops.cc:12:5",
+			want: StackFrame{StackFrameNum: 0, FuncName: "ns::operator<<", Args: "os=0x00007ffff7e1e5c0, v=0x00007fffffffe0a0", SrcFile: "ops.cc", LineNum: 12},
+		},
+		{
+			line: "  * frame #0: 0x0000555555555139 test`Vec<int>::operator<(this=0x00007fffffffe0a0, other=0x00007fffffffe0b0) const at vec.h:40:3",
+			want: StackFrame{StackFrameNum: 0, FuncName: "Vec<int>::operator<", Args: "this=0x00007fffffffe0a0, other=0x00007fffffffe0b0", SrcFile: "vec.h", LineNum: 40},
+		},
+		// Quoted arguments containing " + ", "(" and " at ".
+		{
+			line: "  * frame #0: 0x0000555555555160 test`foo(s=\"a + b\") at test.c:19:2",
+			want: StackFrame{StackFrameNum: 0, FuncName: "foo", Args: "s=\"a + b\"", SrcFile: "test.c", LineNum: 19},
+		},
+		{
+			line: "  * frame #0: 0x0000555555555160 test`foo(s=\"f(x) at a.c:1\", c='(') at test.c:19:2",
+			want: StackFrame{StackFrameNum: 0, FuncName: "foo", Args: "s=\"f(x) at a.c:1\", c='('", SrcFile: "test.c", LineNum: 19},
+		},
+		// Non-debugging stack frame.
+		{
+			line: "    frame #2: 0x00007ffff7de70b3 libc.so.6`__libc_start_main + 243",
+			want: StackFrame{StackFrameNum: 2, FuncName: "__libc_start_main", CallSitePC: 0x7ffff7de70b3},
+		},
+		// Address-only stack frame.
+		{
+			line: "    frame #1: 0x00007ffff7a52000",
+			want: StackFrame{StackFrameNum: 1, FuncName: UnknownFuncName, Unknown: true, CallSitePC: 0x7ffff7a52000},
+		},
+	}
+	for _, g := range golden {
+		got, ok, err := parseLLDBStackFrame(g.line)
+		if err != nil {
+			t.Errorf("%q: unable to parse stack frame; %v", g.line, err)
+			continue
+		}
+		if !ok {
+			t.Errorf("%q: stack frame not recognized", g.line)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("%q: stack frame mismatch; expected %+v, got %+v", g.line, g.want, got)
+		}
+	}
+	// Non stack frame lines.
+	for _, line := range []string{"* thread #1, name = 'test', stop reason = breakpoint 1.1", ""} {
+		if _, ok, err := parseLLDBStackFrame(line); ok || err != nil {
+			t.Errorf("%q: unexpected stack frame (ok=%v, err=%v)", line, ok, err)
+		}
+	}
+}
+
+func TestLLDBParseFuncs(t *testing.T) {
+	const output = `        Summary: test` + "`" + `main at test.c:9
+        Summary: test` + "`" + `foo(int) at test.cpp:17:3
+        Summary: libc.so.6` + "`" + `__libc_start_main
+`
+	fns, err := NewLLDB().ParseFuncs(output)
+	if err != nil {
+		t.Fatalf("unable to parse functions; %+v", err)
+	}
+	got := make(map[string]Func)
+	for _, fn := range fns {
+		got[fn.Sig] = fn
+	}
+	golden := []struct {
+		sig      string
+		file     string
+		line     int
+		nonDebug bool
+	}{
+		{sig: "main", file: "test.c", line: 9},
+		{sig: "foo(int)", file: "test.cpp", line: 17},
+		{sig: "__libc_start_main", nonDebug: true},
+	}
+	if len(fns) != len(golden) {
+		t.Fatalf("number of functions mismatch; expected %d, got %d (%+v)", len(golden), len(fns), fns)
+	}
+	for _, g := range golden {
+		fn, ok := got[g.sig]
+		if !ok {
+			t.Errorf("missing function %q in %+v", g.sig, fns)
+			continue
+		}
+		if fn.File != g.file || fn.Line != g.line || fn.NonDebug != g.nonDebug {
+			t.Errorf("function %q mismatch; expected file %q, line %d, non-debug %v; got %+v", g.sig, g.file, g.line, g.nonDebug, fn)
+		}
+	}
+}
+
+func TestParseStackFrameGDBLLDB(t *testing.T) {
+	// Equivalent GDB and LLDB stack frame lines.
+	golden := []struct {
+		gdb  string
+		lldb string
+	}{
+		{
+			gdb:  "#1  0x0000555555555152 in main (argc=1, argv=0x7fffffffe6a8) at test.c:11",
+			lldb: "    frame #1: 0x0000555555555152 test`main(argc=1, argv=0x7fffffffe6a8) at test.c:11:2",
+		},
+		{
+			gdb:  "#0  (anonymous namespace)::helper (x=1) at anon.cc:3",
+			lldb: "  * frame #0: 0x0000555555555139 test`(anonymous namespace)::helper(x=1) at anon.cc:3:2",
+		},
+		{
+			gdb:  "#1  0x0000555555555bd4 in std::map<int, std::string>::insert (this=0x7fffffffe0a0, v=...) at map.h:55",
+			lldb: "    frame #1: 0x0000555555555bd4 test`std::map<int, std::string>::insert(this=0x7fffffffe0a0, v=...) at map.h:55:7",
+		},
+		{
+			gdb:  "#0  ns::operator<< (os=..., v=...) at ops.cc:12",
+			lldb: "  * frame #0: 0x0000555555555139 test`ns::operator<<(os=..., v=...) at ops.cc:12:5",
+		},
+		{
+			gdb:  "#1  0x0000555555555171 in Widget::operator() (this=0x7fffffffe0a7, x=1) at widget.cc:9",
+			lldb: "    frame #1: 0x0000555555555171 test`Widget::operator()(this=0x7fffffffe0a7, x=1) const at widget.cc:9:3",
+		},
+		{
+			gdb:  "#0  foo (s=0x555555556004 \"a + b (c)\") at test.c:19",
+			lldb: "  * frame #0: 0x0000555555555160 test`foo(s=0x555555556004 \"a + b (c)\") at test.c:19:2",
+		},
+	}
+	g := NewGDB()
+	for _, gg := range golden {
+		want, err := g.parseStrackTrace(gg.gdb)
+		if err != nil {
+			t.Errorf("%q: unable to parse GDB stack frame; %v", gg.gdb, err)
+			continue
+		}
+		got, ok, err := parseLLDBStackFrame(gg.lldb)
+		if err != nil || !ok {
+			t.Errorf("%q: unable to parse LLDB stack frame (ok=%v); %v", gg.lldb, ok, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%q: stack frame mismatch with GDB; expected %+v, got %+v", gg.lldb, want, got)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package callgraph
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+)
+
+// dbg is a logger with the "callgraph:" prefix which logs diagnostic messages
+// (e.g. parsed edges); discarded unless enabled by SetDebugOutput.
+var dbg = log.New(ioutil.Discard, "callgraph: ", 0)
+
+// SetDebugOutput sets the output destination of diagnostic messages (e.g.
+// os.Stderr). A nil w discards diagnostic messages, which is the default.
+func SetDebugOutput(w io.Writer) {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	dbg.SetOutput(w)
+}
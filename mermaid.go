@@ -0,0 +1,67 @@
+package callgraph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WriteMermaid writes the given call graph to w in Mermaid flowchart
+// syntax. Repeated edges are drawn once (see CollapseEdges), labelled with the
+// callee arguments of the first occurrence and the number of occurrences.
+//
+// Example output:
+//
+//    flowchart TD
+//       %% n0: main
+//       %% n1: foo
+//       n0["main"]
+//       n1["foo"]
+//       n0 -->|"(n=23) ×2"| n1
+func WriteMermaid(w io.Writer, edges []Edge) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("flowchart TD\n")
+	ids := newNodeIDs(edges)
+	// Legend mapping node IDs back to function names.
+	for _, name := range ids.names {
+		fmt.Fprintf(buf, "\t%%%% %s: %s\n", ids.id(name), name)
+	}
+	for _, name := range ids.names {
+		fmt.Fprintf(buf, "\t%s[\"%s\"]\n", ids.id(name), mermaidEscape(name))
+	}
+	zero := StackFrame{}
+	for _, edge := range CollapseEdges(edges) {
+		if edge.Src == zero {
+			// Caller information missing; node already declared.
+			continue
+		}
+		src := ids.id(edge.Src.FuncName)
+		dst := ids.id(edge.Dst.FuncName)
+		var labels []string
+		if len(edge.Dst.Args) > 0 {
+			labels = append(labels, "("+edge.Dst.Args+")")
+		}
+		if edge.Count > 1 {
+			labels = append(labels, fmt.Sprintf("×%d", edge.Count))
+		}
+		if len(labels) > 0 {
+			label := strings.Join(labels, " ")
+			fmt.Fprintf(buf, "\t%s -->|\"%s\"| %s\n", src, mermaidEscape(label), dst)
+		} else {
+			fmt.Fprintf(buf, "\t%s --> %s\n", src, dst)
+		}
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// mermaidEscape escapes the given string for use within a quoted Mermaid
+// label.
+func mermaidEscape(s string) string {
+	return strings.Replace(s, `"`, "#quot;", -1)
+}
@@ -0,0 +1,38 @@
+package callgraph
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMermaid(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	edges := []Edge{
+		{Dst: main},
+		{Src: main, Dst: StackFrame{FuncName: "foo", Args: "n=23"}},
+		{Src: main, Dst: StackFrame{FuncName: "foo", Args: "n=42"}},
+		{Src: main, Dst: StackFrame{FuncName: "bar"}},
+		{Src: main, Dst: StackFrame{FuncName: "bar"}},
+		{Src: main, Dst: StackFrame{FuncName: "baz", Args: `s="a"`}},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteMermaid(buf, edges); err != nil {
+		t.Fatalf("unable to write Mermaid; %+v", err)
+	}
+	const want = `flowchart TD
+	%% n0: main
+	%% n1: foo
+	%% n2: bar
+	%% n3: baz
+	n0["main"]
+	n1["foo"]
+	n2["bar"]
+	n3["baz"]
+	n0 -->|"(n=23) ×2"| n1
+	n0 -->|"×2"| n2
+	n0 -->|"(s=#quot;a#quot;)"| n3
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Mermaid output mismatch; expected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,44 @@
+package callgraph
+
+import (
+	"fmt"
+)
+
+// nodeIDs maps each unique function name of a call graph to a stable short
+// node identifier, assigned in order of first appearance.
+type nodeIDs struct {
+	// Unique function names in order of first appearance.
+	names []string
+	// Index into names, keyed by function name.
+	index map[string]int
+}
+
+// newNodeIDs returns a mapping from the unique function names of the given call
+// graph to node identifiers.
+func newNodeIDs(edges []Edge) *nodeIDs {
+	ids := &nodeIDs{
+		index: make(map[string]int),
+	}
+	zero := StackFrame{}
+	for _, edge := range edges {
+		if edge.Src != zero {
+			ids.add(edge.Src.FuncName)
+		}
+		ids.add(edge.Dst.FuncName)
+	}
+	return ids
+}
+
+// add adds the given function name to the mapping if not already present.
+func (ids *nodeIDs) add(name string) {
+	if _, ok := ids.index[name]; ok {
+		return
+	}
+	ids.index[name] = len(ids.names)
+	ids.names = append(ids.names, name)
+}
+
+// id returns the node identifier of the given function name.
+func (ids *nodeIDs) id(name string) string {
+	return fmt.Sprintf("n%d", ids.index[name])
+}
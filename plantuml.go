@@ -0,0 +1,61 @@
+package callgraph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WritePlantUML writes the given call graph to w as a PlantUML component
+// diagram, with one relationship per unique edge.
+//
+// Function names (e.g. "ns::foo(int)") are declared as quoted components with
+// node ID aliases, as PlantUML does not permit "::" or parentheses in
+// unquoted component names.
+//
+// Example output:
+//
+//    @startuml
+//    component "main" as n0
+//    component "foo" as n1
+//    n0 --> n1 : (n=23)
+//    @enduml
+func WritePlantUML(w io.Writer, edges []Edge) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("@startuml\n")
+	ids := newNodeIDs(edges)
+	for _, name := range ids.names {
+		fmt.Fprintf(buf, "component \"%s\" as %s\n", plantUMLEscape(name), ids.id(name))
+	}
+	zero := StackFrame{}
+	for _, edge := range CollapseEdges(edges) {
+		if edge.Src == zero {
+			// Caller information missing; node already declared.
+			continue
+		}
+		src := ids.id(edge.Src.FuncName)
+		dst := ids.id(edge.Dst.FuncName)
+		if len(edge.Dst.Args) > 0 {
+			args := "(" + edge.Dst.Args + ")"
+			fmt.Fprintf(buf, "%s --> %s : %s\n", src, dst, plantUMLEscape(args))
+		} else {
+			fmt.Fprintf(buf, "%s --> %s\n", src, dst)
+		}
+	}
+	buf.WriteString("@enduml\n")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// plantUMLEscape escapes the given string for use within a quoted PlantUML
+// name or label. PlantUML has no escape sequence for double quotes, so they
+// are replaced by single quotes.
+func plantUMLEscape(s string) string {
+	s = strings.Replace(s, `"`, `'`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
@@ -0,0 +1,82 @@
+package callgraph
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTimeout is returned when the debugger is killed for exceeding the tracing
+// timeout. Call graph edges parsed from the output captured before the timeout
+// may be returned alongside ErrTimeout.
+var ErrTimeout = errors.New("debugger timed out")
+
+// ErrExitStatus is returned when the debugger exits with a non-zero exit status
+// (e.g. as the traced program crashed). Call graph edges parsed from the output
+// captured before the debugger exited may be returned alongside ErrExitStatus.
+var ErrExitStatus = errors.New("debugger exited with non-zero exit status")
+
+// IsPartial reports whether the given error is returned alongside call graph
+// edges of partial debugger output (i.e. ErrTimeout or ErrExitStatus).
+func IsPartial(err error) bool {
+	cause := errors.Cause(err)
+	return cause == ErrTimeout || cause == ErrExitStatus
+}
+
+// newContext returns a context which expires after the tracing timeout, or
+// never expires if no timeout has been specified.
+func (o *Options) newContext() (context.Context, context.CancelFunc) {
+	if o.Timeout > 0 {
+		return context.WithTimeout(context.Background(), o.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// runProcessGroup runs the given command, created with the specified context,
+// in a new process group. The entire process group is killed if the context
+// expires before the command completes, in which case ErrTimeout is returned.
+// ErrExitStatus is returned if the command exits with a non-zero exit status.
+//
+// If interrupt is non-zero, the command is sent an interrupt signal after the
+// given duration (e.g. to stop a traced program which the debugger has
+// attached to).
+func runProcessGroup(ctx context.Context, cmd *exec.Cmd, interrupt time.Duration) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return errors.WithStack(err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	var interruptc <-chan time.Time
+	if interrupt > 0 {
+		timer := time.NewTimer(interrupt)
+		defer timer.Stop()
+		interruptc = timer.C
+	}
+	go func() {
+		for {
+			select {
+			case <-interruptc:
+				interruptProcess(cmd)
+				interruptc = nil
+			case <-ctx.Done():
+				// Kill the traced program as well as the debugger, as the traced
+				// program would otherwise keep the output pipes open.
+				killProcessGroup(cmd)
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	err := cmd.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.WithStack(ErrTimeout)
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return errors.Wrap(ErrExitStatus, exitErr.Error())
+	}
+	return err
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package callgraph
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup configures the given command to run in a new process group,
+// so that the debugger and the traced program may be killed together.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the process group of the given started command.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// interruptProcess sends an interrupt signal to the given started command.
+func interruptProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Signal(syscall.SIGINT)
+}
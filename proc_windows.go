@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package callgraph
+
+import "os/exec"
+
+// setProcessGroup configures the given command to run in a new process group,
+// so that the debugger and the traced program may be killed together. Process
+// groups are not supported on Windows.
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// killProcessGroup kills the process group of the given started command. Only
+// the process itself is killed on Windows.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// interruptProcess sends an interrupt signal to the given started command.
+// Interrupt signals are not supported on Windows, so the process is killed
+// instead.
+func interruptProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
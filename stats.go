@@ -0,0 +1,146 @@
+package callgraph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Stats summarizes the shape of a call graph (e.g. as a health check of a
+// trace).
+type Stats struct {
+	// Number of functions.
+	Nodes int
+	// Number of distinct caller/callee pairs.
+	Edges int
+	// Number of recorded calls.
+	Calls int
+	// Number of roots (i.e. functions without callers).
+	Roots int
+	// Number of leaves (i.e. functions without callees).
+	Leaves int
+	// Maximum call depth from the roots (see CallDepths).
+	MaxDepth int
+	// Number of strongly connected components containing cycles (see
+	// FindCycles).
+	Cycles int
+	// Functions with the highest in-degree, in descending order.
+	TopIn []FuncDegree
+	// Functions with the highest out-degree, in descending order.
+	TopOut []FuncDegree
+}
+
+// FuncDegree is the in-degree or out-degree of a function.
+type FuncDegree struct {
+	// Function name.
+	FuncName string
+	// Number of distinct callers or callees.
+	Degree int
+}
+
+// topDegrees is the number of functions listed by in-degree and out-degree in
+// call graph statistics.
+const topDegrees = 10
+
+// GraphStats returns statistics of the given call graph. Return edges (see
+// EdgeReturn) are ignored.
+func GraphStats(edges []Edge) Stats {
+	edges, _ = SplitReturns(edges)
+	zero := StackFrame{}
+	var stats Stats
+	for _, edge := range CollapseEdges(edges) {
+		if edge.Src == zero {
+			continue
+		}
+		stats.Edges++
+		stats.Calls += edge.Count
+	}
+	degrees := Degrees(edges)
+	var in, out []FuncDegree
+	for name, degree := range degrees {
+		stats.Nodes++
+		if degree.In == 0 {
+			stats.Roots++
+		}
+		if degree.Out == 0 {
+			stats.Leaves++
+		}
+		in = append(in, FuncDegree{FuncName: name, Degree: degree.In})
+		out = append(out, FuncDegree{FuncName: name, Degree: degree.Out})
+	}
+	for _, depth := range CallDepths(edges) {
+		if depth > stats.MaxDepth {
+			stats.MaxDepth = depth
+		}
+	}
+	stats.Cycles = len(FindCycles(edges))
+	stats.TopIn = topFuncDegrees(in, topDegrees)
+	stats.TopOut = topFuncDegrees(out, topDegrees)
+	return stats
+}
+
+// topFuncDegrees returns the n functions of highest degree among the given
+// function degrees, in descending order of degree (and ascending order of
+// function name for functions of the same degree). Functions of degree 0 are
+// omitted.
+func topFuncDegrees(degrees []FuncDegree, n int) []FuncDegree {
+	sort.Slice(degrees, func(i, j int) bool {
+		if degrees[i].Degree != degrees[j].Degree {
+			return degrees[i].Degree > degrees[j].Degree
+		}
+		return degrees[i].FuncName < degrees[j].FuncName
+	})
+	var top []FuncDegree
+	for _, degree := range degrees {
+		if len(top) == n || degree.Degree == 0 {
+			break
+		}
+		top = append(top, degree)
+	}
+	return top
+}
+
+// WriteStats writes the given call graph statistics to w, as one "key: value"
+// line per statistic followed by the functions of highest in-degree and
+// out-degree.
+//
+// Example output:
+//
+//    nodes: 4
+//    edges: 3
+//    calls: 5
+//    roots: 1
+//    leaves: 1
+//    max depth: 3
+//    cycles: 0
+//    top in-degree:
+//       2 bar
+//       1 foo
+//    top out-degree:
+//       2 foo
+//       1 main
+func WriteStats(w io.Writer, stats Stats) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "nodes: %d\n", stats.Nodes)
+	fmt.Fprintf(buf, "edges: %d\n", stats.Edges)
+	fmt.Fprintf(buf, "calls: %d\n", stats.Calls)
+	fmt.Fprintf(buf, "roots: %d\n", stats.Roots)
+	fmt.Fprintf(buf, "leaves: %d\n", stats.Leaves)
+	fmt.Fprintf(buf, "max depth: %d\n", stats.MaxDepth)
+	fmt.Fprintf(buf, "cycles: %d\n", stats.Cycles)
+	fmt.Fprintf(buf, "top in-degree:\n")
+	for _, degree := range stats.TopIn {
+		fmt.Fprintf(buf, "\t%d %s\n", degree.Degree, degree.FuncName)
+	}
+	fmt.Fprintf(buf, "top out-degree:\n")
+	for _, degree := range stats.TopOut {
+		fmt.Fprintf(buf, "\t%d %s\n", degree.Degree, degree.FuncName)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
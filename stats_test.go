@@ -0,0 +1,100 @@
+package callgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphStats(t *testing.T) {
+	main := StackFrame{FuncName: "main"}
+	foo := StackFrame{FuncName: "foo"}
+	bar := StackFrame{FuncName: "bar"}
+	baz := StackFrame{FuncName: "baz"}
+	edges := []Edge{
+		{Dst: main},
+		{Src: main, Dst: foo},
+		{Src: main, Dst: foo},
+		{Src: main, Dst: bar},
+		{Src: foo, Dst: baz},
+		{Src: bar, Dst: baz},
+		{Src: baz, Dst: baz},
+		// Return edges of -returns.
+		{Src: baz, Dst: foo, Kind: EdgeReturn},
+		{Src: foo, Dst: main, Kind: EdgeReturn},
+	}
+	want := Stats{
+		Nodes:    4,
+		Edges:    5,
+		Calls:    6,
+		Roots:    1,
+		Leaves:   0,
+		MaxDepth: 2,
+		Cycles:   1,
+		TopIn: []FuncDegree{
+			{FuncName: "baz", Degree: 3},
+			{FuncName: "bar", Degree: 1},
+			{FuncName: "foo", Degree: 1},
+		},
+		TopOut: []FuncDegree{
+			{FuncName: "main", Degree: 2},
+			{FuncName: "bar", Degree: 1},
+			{FuncName: "baz", Degree: 1},
+			{FuncName: "foo", Degree: 1},
+		},
+	}
+	if got := GraphStats(edges); !reflect.DeepEqual(got, want) {
+		t.Errorf("stats mismatch; expected %+v, got %+v", want, got)
+	}
+	// Leaves without recursion.
+	got := GraphStats(edges[:6])
+	if got.Roots != 1 || got.Leaves != 1 {
+		t.Errorf("roots and leaves mismatch; expected 1 and 1, got %d and %d", got.Roots, got.Leaves)
+	}
+}
+
+func TestTopFuncDegrees(t *testing.T) {
+	degrees := []FuncDegree{
+		{FuncName: "c", Degree: 2},
+		{FuncName: "e", Degree: 0},
+		{FuncName: "b", Degree: 2},
+		{FuncName: "d", Degree: 1},
+		{FuncName: "a", Degree: 3},
+		{FuncName: "f", Degree: 2},
+	}
+	golden := []struct {
+		n    int
+		want []FuncDegree
+	}{
+		// Ties ordered by function name; functions of degree 0 omitted.
+		{
+			n: 10,
+			want: []FuncDegree{
+				{FuncName: "a", Degree: 3},
+				{FuncName: "b", Degree: 2},
+				{FuncName: "c", Degree: 2},
+				{FuncName: "f", Degree: 2},
+				{FuncName: "d", Degree: 1},
+			},
+		},
+		// Ties cut off by n.
+		{
+			n: 3,
+			want: []FuncDegree{
+				{FuncName: "a", Degree: 3},
+				{FuncName: "b", Degree: 2},
+				{FuncName: "c", Degree: 2},
+			},
+		},
+		{
+			n:    0,
+			want: nil,
+		},
+	}
+	for _, g := range golden {
+		in := make([]FuncDegree, len(degrees))
+		copy(in, degrees)
+		if got := topFuncDegrees(in, g.n); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("n=%d: top degrees mismatch; expected %+v, got %+v", g.n, g.want, got)
+		}
+	}
+}
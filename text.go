@@ -0,0 +1,50 @@
+package callgraph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// WriteText writes the given call graph to w as a plain text edge list, with
+// one "caller -> callee" line per deduplicated edge, sorted by caller and
+// callee function name (e.g. to grep or diff call graphs in a terminal). Edges
+// with missing caller information (i.e. roots) are written as "-> callee".
+// Return edges (see EdgeReturn) are ignored. If showCount is set, the number of
+// occurrences of each edge is appended to its line, separated by a tab.
+//
+// Example output:
+//
+//    -> main
+//    foo -> bar
+//    main -> foo
+func WriteText(w io.Writer, edges []Edge, showCount bool) error {
+	edges, _ = SplitReturns(edges)
+	counted := CollapseEdges(edges)
+	sort.SliceStable(counted, func(i, j int) bool {
+		if counted[i].Src.FuncName != counted[j].Src.FuncName {
+			return counted[i].Src.FuncName < counted[j].Src.FuncName
+		}
+		return counted[i].Dst.FuncName < counted[j].Dst.FuncName
+	})
+	zero := StackFrame{}
+	buf := &bytes.Buffer{}
+	for _, edge := range counted {
+		if edge.Src == zero {
+			fmt.Fprintf(buf, "-> %s", edge.Dst.FuncName)
+		} else {
+			fmt.Fprintf(buf, "%s -> %s", edge.Src.FuncName, edge.Dst.FuncName)
+		}
+		if showCount {
+			fmt.Fprintf(buf, "\t%d", edge.Count)
+		}
+		buf.WriteString("\n")
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
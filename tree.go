@@ -0,0 +1,81 @@
+package callgraph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// WriteTree writes the given call graph to w as an indented ASCII tree of the
+// call hierarchy, rooted at the roots of the call graph (i.e. callees with
+// missing caller information, and callers which are never called themselves).
+//
+// Subtrees of functions already printed are replaced by a "(see above)" marker,
+// and back-edges of cycles (i.e. recursion) are marked "(recursive)".
+//
+// Example output:
+//
+//    main
+//    ├── foo
+//    │   └── bar
+//    │       └── foo (recursive)
+//    └── bar (see above)
+func WriteTree(w io.Writer, edges []Edge) error {
+	g := NewGraph(edges)
+	zero := StackFrame{}
+	called := make(map[string]bool)
+	for _, edge := range edges {
+		if edge.Src != zero {
+			called[edge.Dst.FuncName] = true
+		}
+	}
+	buf := &bytes.Buffer{}
+	// Functions already printed.
+	printed := make(map[string]bool)
+	// Functions on the current path from the root.
+	onPath := make(map[string]bool)
+	var writeNode func(name, prefix string)
+	writeNode = func(name, prefix string) {
+		printed[name] = true
+		onPath[name] = true
+		callees := g.Callees(name)
+		for i, callee := range callees {
+			branch, indent := "├── ", "│   "
+			if i == len(callees)-1 {
+				branch, indent = "└── ", "    "
+			}
+			switch {
+			case onPath[callee]:
+				fmt.Fprintf(buf, "%s%s%s (recursive)\n", prefix, branch, callee)
+			case printed[callee]:
+				fmt.Fprintf(buf, "%s%s%s (see above)\n", prefix, branch, callee)
+			default:
+				fmt.Fprintf(buf, "%s%s%s\n", prefix, branch, callee)
+				writeNode(callee, prefix+indent)
+			}
+		}
+		onPath[name] = false
+	}
+	writeRoot := func(name string) {
+		if printed[name] {
+			return
+		}
+		fmt.Fprintf(buf, "%s\n", name)
+		writeNode(name, "")
+	}
+	for _, name := range g.Nodes() {
+		if !called[name] {
+			writeRoot(name)
+		}
+	}
+	// Functions only reachable through cycles without a root.
+	for _, name := range g.Nodes() {
+		writeRoot(name)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}